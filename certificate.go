@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Keeps the serving certificate, which can be swapped without restarting the
+// server, as new TLS handshakes ask for it through GetCertificate.
+type certificateHolder struct {
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+}
+
+func (holder *certificateHolder) get() *tls.Certificate {
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	return holder.certificate
+}
+
+func (holder *certificateHolder) set(certificate *tls.Certificate) error {
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return err
+	}
+	certificate.Leaf = leaf
+
+	holder.mu.Lock()
+	holder.certificate = certificate
+	holder.mu.Unlock()
+
+	tlsCertificateExpiry.Set(float64(leaf.NotAfter.Unix()))
+	log.Infof("Serving certificate for %v valid until %v", leaf.DNSNames, leaf.NotAfter)
+	return nil
+}
+
+func (holder *certificateHolder) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate := holder.get()
+	if certificate == nil {
+		return nil, errors.New("no serving certificate loaded")
+	}
+	return certificate, nil
+}
+
+// Reloads the certificate whenever the content of its files changes, e.g. when
+// the mounted Secret gets rotated.
+type certificateFileWatcher struct {
+	certFile string
+	keyFile  string
+	holder   *certificateHolder
+
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func (watcher *certificateFileWatcher) load() error {
+	certPEM, err := ioutil.ReadFile(watcher.certFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := ioutil.ReadFile(watcher.keyFile)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(certPEM, watcher.certPEM) && bytes.Equal(keyPEM, watcher.keyPEM) {
+		return nil
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+	if err := watcher.holder.set(&certificate); err != nil {
+		return err
+	}
+	log.Infof("Loaded certificate from %s and %s", watcher.certFile, watcher.keyFile)
+
+	watcher.certPEM, watcher.keyPEM = certPEM, keyPEM
+	return nil
+}
+
+// Checks the files periodically. A broken or half-written pair of files keeps
+// the previous certificate in place until the next successful load.
+func (watcher *certificateFileWatcher) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := watcher.load(); err != nil {
+			log.Errorf("Could not reload certificate: %v", err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCertificate(t *testing.T, certFile string, keyFile string, notAfter time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "webhook"},
+		DNSNames:     []string{"webhook"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+func TestCertificateReload(t *testing.T) {
+	initLogger()
+	dir, err := ioutil.TempDir("", "certificate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	holder := &certificateHolder{}
+	watcher := &certificateFileWatcher{certFile: certFile, keyFile: keyFile, holder: holder}
+
+	t.Run("should fail without certificate", func(t *testing.T) {
+		_, err := holder.GetCertificate(nil)
+		assert.Error(t, err)
+		assert.Error(t, watcher.load())
+	})
+
+	firstExpiry := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	writeTestCertificate(t, certFile, keyFile, firstExpiry)
+
+	t.Run("should load certificate", func(t *testing.T) {
+		assert.NoError(t, watcher.load())
+		certificate, err := holder.GetCertificate(nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, firstExpiry.UTC(), certificate.Leaf.NotAfter)
+		}
+		assert.Equal(t, float64(firstExpiry.Unix()), testutil.ToFloat64(tlsCertificateExpiry))
+	})
+
+	t.Run("should keep certificate when files are broken", func(t *testing.T) {
+		assert.NoError(t, ioutil.WriteFile(keyFile, []byte("garbage"), 0600))
+		assert.Error(t, watcher.load())
+		assert.Equal(t, firstExpiry.UTC(), holder.get().Leaf.NotAfter)
+	})
+
+	t.Run("should swap certificate when files change", func(t *testing.T) {
+		secondExpiry := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+		writeTestCertificate(t, certFile, keyFile, secondExpiry)
+		assert.NoError(t, watcher.load())
+		assert.Equal(t, secondExpiry.UTC(), holder.get().Leaf.NotAfter)
+		assert.Equal(t, float64(secondExpiry.Unix()), testutil.ToFloat64(tlsCertificateExpiry))
+	})
+}
@@ -1,61 +1,222 @@
 package main
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type enforcementLevel string
+
+const (
+	enforcementDeny enforcementLevel = "deny"
+	enforcementWarn enforcementLevel = "warn"
+	enforcementOff  enforcementLevel = "off"
+)
+
+const (
+	modeEnforce = "enforce"
+	modeAudit   = "audit"
 )
 
 type config struct {
-	NoTLS                                                       bool   `mapstructure:"no-tls"`
-	TLSCertFile                                                 string `mapstructure:"tls-cert-file"`
-	TLSPrivateKeyFile                                           string `mapstructure:"tls-private-key-file"`
-	ListenPort                                                  int    `mapstructure:"listen-port"`
-	RuleResourceViolationMessage                                string `mapstructure:"rule-resource-violation-message"`
-	RuleResourceLimitCPURequired                                bool   `mapstructure:"rule-resource-limit-cpu-required"`
-	RuleResourceLimitCPUMustBeNonZero                           bool   `mapstructure:"rule-resource-limit-cpu-must-be-nonzero"`
-	RuleResourceLimitMemoryRequired                             bool   `mapstructure:"rule-resource-limit-memory-required"`
-	RuleResourceLimitMemoryMustBeNonZero                        bool   `mapstructure:"rule-resource-limit-memory-must-be-nonzero"`
-	RuleResourceRequestCPURequired                              bool   `mapstructure:"rule-resource-request-cpu-required"`
-	RuleResourceRequestCPUMustBeNonZero                         bool   `mapstructure:"rule-resource-request-cpu-must-be-nonzero"`
-	RuleResourceRequestMemoryRequired                           bool   `mapstructure:"rule-resource-request-memory-required"`
-	RuleResourceRequestMemoryMustBeNonZero                      bool   `mapstructure:"rule-resource-request-memory-must-be-nonzero"`
-	RuleSecurityReadonlyRootFilesystemRequired                  bool   `mapstructure:"rule-security-readonly-rootfs-required"`
-	RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled  bool   `mapstructure:"rule-security-readonly-rootfs-required-whitelist-enabled"`
-	RuleIngressCollision                                        bool   `mapstructure:"rule-ingress-collision"`
-	RuleIngressViolationMessage                                 string `mapstructure:"rule-ingress-violation-message"`
-	AnnotationsPrefix                                           string `mapstructure:"annotations-prefix"`
-	Namespace                                                   string `mapstructure:"namespace"`
+	NoTLS                                                      bool          `mapstructure:"no-tls"`
+	TLSCertFile                                                string        `mapstructure:"tls-cert-file"`
+	TLSPrivateKeyFile                                          string        `mapstructure:"tls-private-key-file"`
+	TLSReloadInterval                                          time.Duration `mapstructure:"tls-reload-interval"`
+	SelfSignedTLS                                              bool          `mapstructure:"self-signed-tls"`
+	SelfSignedTLSServiceDNSName                                string        `mapstructure:"self-signed-tls-service-dns-name"`
+	SelfSignedTLSSecretNamespace                               string        `mapstructure:"self-signed-tls-secret-namespace"`
+	SelfSignedTLSSecretName                                    string        `mapstructure:"self-signed-tls-secret-name"`
+	SelfSignedTLSValidity                                      time.Duration `mapstructure:"self-signed-tls-validity"`
+	SelfSignedTLSRenewBefore                                   time.Duration `mapstructure:"self-signed-tls-renew-before"`
+	WebhookConfigurationName                                   string        `mapstructure:"webhook-configuration-name"`
+	MutatingWebhookConfigurationName                           string        `mapstructure:"mutating-webhook-configuration-name"`
+	RegisterWebhook                                            bool          `mapstructure:"register-webhook"`
+	WebhookServiceName                                         string        `mapstructure:"webhook-service-name"`
+	WebhookServiceNamespace                                    string        `mapstructure:"webhook-service-namespace"`
+	WebhookCABundleFile                                        string        `mapstructure:"webhook-ca-bundle-file"`
+	WebhookFailurePolicy                                       string        `mapstructure:"webhook-failure-policy"`
+	WebhookTimeoutSeconds                                      int32         `mapstructure:"webhook-timeout-seconds"`
+	WebhookNamespaceSelector                                   string        `mapstructure:"webhook-namespace-selector"`
+	ListenPort                                                 int           `mapstructure:"listen-port"`
+	HealthListenPort                                           int           `mapstructure:"health-listen-port"`
+	Mode                                                       string        `mapstructure:"mode"`
+	RuleResourceViolationMessage                               string        `mapstructure:"rule-resource-violation-message"`
+	RuleResourceLimitCPURequired                               bool          `mapstructure:"rule-resource-limit-cpu-required"`
+	RuleResourceLimitCPUMustBeNonZero                          bool          `mapstructure:"rule-resource-limit-cpu-must-be-nonzero"`
+	RuleResourceLimitMemoryRequired                            bool          `mapstructure:"rule-resource-limit-memory-required"`
+	RuleResourceLimitMemoryMustBeNonZero                       bool          `mapstructure:"rule-resource-limit-memory-must-be-nonzero"`
+	RuleResourceRequestCPURequired                             bool          `mapstructure:"rule-resource-request-cpu-required"`
+	RuleResourceRequestCPUMustBeNonZero                        bool          `mapstructure:"rule-resource-request-cpu-must-be-nonzero"`
+	RuleResourceRequestMemoryRequired                          bool          `mapstructure:"rule-resource-request-memory-required"`
+	RuleResourceRequestMemoryMustBeNonZero                     bool          `mapstructure:"rule-resource-request-memory-must-be-nonzero"`
+	RuleSecurityReadonlyRootFilesystemRequired                 bool          `mapstructure:"rule-security-readonly-rootfs-required"`
+	RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled bool          `mapstructure:"rule-security-readonly-rootfs-required-whitelist-enabled"`
+	securityContextConfig                                      `mapstructure:",squash"`
+	capabilitiesConfig                                         `mapstructure:",squash"`
+	hostConfig                                                 `mapstructure:",squash"`
+	hostPathConfig                                             `mapstructure:",squash"`
+	imageRegistriesConfig                                      `mapstructure:",squash"`
+	imageReferenceConfig                                       `mapstructure:",squash"`
+	probesConfig                                               `mapstructure:",squash"`
+	resourceBoundsConfig                                       `mapstructure:",squash"`
+	resourceNamesConfig                                        `mapstructure:",squash"`
+	RuleIngressCollision                                       bool          `mapstructure:"rule-ingress-collision"`
+	RuleIngressViolationMessage                                string        `mapstructure:"rule-ingress-violation-message"`
+	RuleEnforcement                                            []string      `mapstructure:"rule-enforcement"`
+	DefaultResourceLimitCPU                                    string        `mapstructure:"default-resource-limit-cpu"`
+	DefaultResourceLimitMemory                                 string        `mapstructure:"default-resource-limit-memory"`
+	DefaultResourceRequestCPU                                  string        `mapstructure:"default-resource-request-cpu"`
+	DefaultResourceRequestMemory                               string        `mapstructure:"default-resource-request-memory"`
+	NamespaceOverrides                                         bool          `mapstructure:"namespace-overrides"`
+	NamespaceCacheTTL                                          time.Duration `mapstructure:"namespace-cache-ttl"`
+	PolicyFile                                                 string        `mapstructure:"policy-file"`
+	PolicyReloadInterval                                       time.Duration `mapstructure:"policy-reload-interval"`
+	ExcludeNamespaces                                          []string      `mapstructure:"exclude-namespaces"`
+	ExcludeUsers                                               []string      `mapstructure:"exclude-users"`
+	ExcludeGroups                                              []string      `mapstructure:"exclude-groups"`
+	ExcludeServiceAccounts                                     []string      `mapstructure:"exclude-service-accounts"`
+	ExemptionsEnabled                                          bool          `mapstructure:"exemptions-enabled"`
+	ExemptableRules                                            []string      `mapstructure:"exemptable-rules"`
+	AnnotationsPrefix                                          string        `mapstructure:"annotations-prefix"`
+	Namespace                                                  string        `mapstructure:"namespace"`
+
+	enforcementLevels  map[string]enforcementLevel
+	excludedNamespaces []*regexp.Regexp
+	profiles           map[string]*policy
+	celRules           []*celRule
+	namespaces         *namespaceCache
+	namespaceConfigs   *namespaceConfigCache
+
+	// parsed parameters of the resource name rules
+	resourceRequiredNames           resourceNames
+	resourceNonZeroNames            resourceNames
+	resourceLimitEqualsRequestNames []corev1.ResourceName
+	// parsed parameters of the resource bounds and ratio rules
+	resourceBounds             []resourceBound
+	resourceLimitRequestRatios map[corev1.ResourceName]float64
+	// compiled parameters of the host rules
+	hostNamespacesAllowedNamespaces []*regexp.Regexp
+	hostPortsAllowedNamespaces      []*regexp.Regexp
+	hostPortsAllowedPorts           portRanges
+	// normalized allowed and namespace-allowed capabilities
+	capabilitiesAllowed      map[string]bool
+	capabilitiesAllowedNames []string
+	// compiled allowed and namespace-allowed registries
+	imageRegistries []imageRegistry
+	// compiled namespaces of the image reference rule
+	digestRequiredNamespaces []*regexp.Regexp
+}
+
+func loadConfig(v *viper.Viper) (*config, error) {
+	config := &config{}
+	if err := v.Unmarshal(config); err != nil {
+		return nil, err
+	}
+	if err := config.init(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Holds the current configuration, which is replaced whenever the policy file
+// changes.
+type configStore struct {
+	mu     sync.RWMutex
+	config *config
+}
+
+func (store *configStore) get() *config {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.config
+}
+
+func (store *configStore) set(config *config) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.config = config
+}
+
+// Parses and checks options which are not just plain values.
+func (config *config) init() error {
+	config.namespaceConfigs = newNamespaceConfigCache()
+	config.enforcementLevels = make(map[string]enforcementLevel)
+	for _, setting := range config.RuleEnforcement {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("Invalid rule enforcement '%s', expected '<rule-id>=<level>'", setting)
+		}
+		ruleID, level := strings.TrimSpace(parts[0]), enforcementLevel(strings.TrimSpace(parts[1]))
+		if config.findRule(ruleID) == nil {
+			return fmt.Errorf("Invalid rule enforcement '%s', unknown rule '%s'", setting, ruleID)
+		}
+		if level != enforcementDeny && level != enforcementWarn && level != enforcementOff {
+			return fmt.Errorf("Invalid rule enforcement '%s', level must be one of 'deny', 'warn' or 'off'", setting)
+		}
+		config.enforcementLevels[ruleID] = level
+	}
+	for _, ruleID := range config.ExemptableRules {
+		if config.findRule(strings.TrimSpace(ruleID)) == nil {
+			return fmt.Errorf("Invalid exemptable rule, unknown rule '%s'", ruleID)
+		}
+	}
+	for _, r := range ruleRegistry {
+		if validated, ok := r.(validatedRule); ok {
+			if err := validated.Validate(config); err != nil {
+				return err
+			}
+		}
+	}
+	return config.initExclusions()
+}
+
+// Returns the enforcement level of a rule, 'deny' unless configured otherwise.
+func (config *config) enforcement(ruleID string) enforcementLevel {
+	if level, ok := config.enforcementLevels[ruleID]; ok {
+		return level
+	}
+	return enforcementDeny
 }
 
 func initCommonFlags(cmd *cobra.Command) {
-	//pod
+	//rules
+	addRuleFlags(cmd)
+
+	//messages
 	cmd.Flags().String("rule-resource-violation-message", "",
 		"Additional message to be included whenever any of the resource-related rules are violated.")
-	cmd.Flags().Bool("rule-resource-limit-cpu-required", false,
-		"Whether 'cpu' limit in resource specifications is required.")
-	cmd.Flags().Bool("rule-resource-limit-cpu-must-be-nonzero", false,
-		"Whether 'cpu' limit in resource specifications must be a nonzero value.")
-	cmd.Flags().Bool("rule-resource-limit-memory-required", false,
-		"Whether 'memory' limit in resource specifications is required.")
-	cmd.Flags().Bool("rule-resource-limit-memory-must-be-nonzero", false,
-		"Whether 'memory' limit in resource specifications must be a nonzero value.")
-	cmd.Flags().Bool("rule-resource-request-cpu-required", false,
-		"Whether 'cpu' request in resource specifications is required.")
-	cmd.Flags().Bool("rule-resource-request-cpu-must-be-nonzero", false,
-		"Whether 'cpu' request in resource specifications must be a nonzero value.")
-	cmd.Flags().Bool("rule-resource-request-memory-required", false,
-		"Whether 'memory' request in resource specifications is required.")
-	cmd.Flags().Bool("rule-resource-request-memory-must-be-nonzero", false,
-		"Whether 'memory' request in resource specifications must be a nonzero value.")
-	cmd.Flags().Bool("rule-security-readonly-rootfs-required", false,
-		"Whether 'readOnlyRootFilesystem' in security context specifications is required.")
-	cmd.Flags().Bool("rule-security-readonly-rootfs-required-whitelist-enabled", false,
-		"Whether rule 'readOnlyRootFilesystem' in security context can be ignored by container whitelisting.")
-
-	//ingress
 	cmd.Flags().String("rule-ingress-violation-message", "",
 		"Additional message to be included whenever any of the ingress-related rules are violated.")
-	cmd.Flags().Bool("rule-ingress-collision", false,
-		"Whether ingress tls and host collision should be checked")
+
+	//enforcement
+	cmd.Flags().StringSlice("rule-enforcement", []string{},
+		"Enforcement level of individual rules as '<rule-id>=<level>', where level is 'deny' (default), 'warn' (allowed with a warning) or 'off'. E.g. 'ingress-collision=warn'.")
+
+	//policy
+	cmd.Flags().String("policy-file", "",
+		"Path to a YAML or JSON policy describing the rules, their parameters, enforcement levels and messages. Applied on top of the rule flags.")
+
+	//namespace overrides
+	cmd.Flags().Bool("namespace-overrides", false,
+		"Whether namespaces can override the rules by annotations named like the rule flags, or select a profile of the policy file by the 'policy-profile' label.")
+
+	//exclusions
+	cmd.Flags().StringSlice("exclude-namespaces", []string{},
+		"Namespaces whose objects are never validated, either names or regular expressions matching whole names. E.g. 'kube-system,monitoring-.*'.")
+
+	//exemptions
+	cmd.Flags().Bool("exemptions-enabled", false,
+		"Whether objects can be exempted from the --exemptable-rules by their annotations.")
+	cmd.Flags().StringSlice("exemptable-rules", []string{},
+		"IDs of the rules objects can be exempted from when --exemptions-enabled is set, e.g. 'resource-limit-cpu-required'.")
 
 	//customizations
 	cmd.Flags().String("annotations-prefix", "admission.validation.avast.com",
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// Compiles --exclude-namespaces, each either a name or a regular expression
+// matching the whole name, and checks --exclude-service-accounts.
+func (config *config) initExclusions() error {
+	excludedNamespaces, err := compileNamespacePatterns(config.ExcludeNamespaces)
+	if err != nil {
+		return fmt.Errorf("Invalid excluded namespace %v", err)
+	}
+	config.excludedNamespaces = excludedNamespaces
+	for _, serviceAccount := range config.ExcludeServiceAccounts {
+		if parts := strings.Split(serviceAccount, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("Invalid excluded service account '%s', expected '<namespace>/<name>'", serviceAccount)
+		}
+	}
+	return nil
+}
+
+// Returns why objects of the namespace, requested by the user, are not
+// validated at all, or an empty string if they are. The user is nil when
+// scanning.
+func (config *config) exclusionReason(namespace string, userInfo *authenticationv1.UserInfo) string {
+	if matchesNamespace(config.excludedNamespaces, namespace) {
+		return fmt.Sprintf("namespace '%s' is excluded", namespace)
+	}
+	if userInfo == nil {
+		return ""
+	}
+	for _, username := range config.ExcludeUsers {
+		if userInfo.Username == username {
+			return fmt.Sprintf("user '%s' is excluded", username)
+		}
+	}
+	for _, serviceAccount := range config.ExcludeServiceAccounts {
+		if userInfo.Username == serviceAccountUsernamePrefix+strings.Replace(serviceAccount, "/", ":", 1) {
+			return fmt.Sprintf("service account '%s' is excluded", serviceAccount)
+		}
+	}
+	for _, group := range config.ExcludeGroups {
+		for _, userGroup := range userInfo.Groups {
+			if userGroup == group {
+				return fmt.Sprintf("group '%s' of user '%s' is excluded", group, userInfo.Username)
+			}
+		}
+	}
+	return ""
+}
+
+// Compiles namespace patterns, each either a name or a regular expression
+// matching the whole name.
+func compileNamespacePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %v", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+func matchesNamespace(patterns []*regexp.Regexp, namespace string) bool {
+	for _, re := range patterns {
+		if namespace != "" && re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
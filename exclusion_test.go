@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestExclusion(t *testing.T) {
+	initLogger()
+
+	newConfig := func(settings map[string]interface{}) *config {
+		config, err := (&config{RuleResourceLimitCPURequired: true}).with(settings)
+		assert.NoError(t, err)
+		return config
+	}
+
+	t.Run("should exclude namespaces by name or regular expression", func(t *testing.T) {
+		config := newConfig(map[string]interface{}{"exclude-namespaces": "kube-system,monitoring-.*"})
+		assert.Equal(t, "namespace 'kube-system' is excluded", config.exclusionReason("kube-system", nil))
+		assert.Equal(t, "namespace 'monitoring-prod' is excluded", config.exclusionReason("monitoring-prod", nil))
+		assert.Empty(t, config.exclusionReason("kube-system-2", nil))
+		assert.Empty(t, config.exclusionReason("team-monitoring-prod", nil))
+		assert.Empty(t, config.exclusionReason("", nil))
+	})
+
+	t.Run("should exclude users, groups and service accounts", func(t *testing.T) {
+		config := newConfig(map[string]interface{}{
+			"exclude-users":            []string{"break-glass"},
+			"exclude-groups":           []string{"system:nodes"},
+			"exclude-service-accounts": []string{"cd/deployer"},
+		})
+		assert.Equal(t, "user 'break-glass' is excluded",
+			config.exclusionReason("test", &authenticationv1.UserInfo{Username: "break-glass"}))
+		assert.Equal(t, "group 'system:nodes' of user 'system:node:node-1' is excluded",
+			config.exclusionReason("test", &authenticationv1.UserInfo{Username: "system:node:node-1", Groups: []string{"system:authenticated", "system:nodes"}}))
+		assert.Equal(t, "service account 'cd/deployer' is excluded",
+			config.exclusionReason("test", &authenticationv1.UserInfo{Username: "system:serviceaccount:cd:deployer"}))
+		assert.Empty(t, config.exclusionReason("test", &authenticationv1.UserInfo{Username: "system:serviceaccount:other:deployer"}))
+		assert.Empty(t, config.exclusionReason("test", &authenticationv1.UserInfo{Username: "developer", Groups: []string{"system:authenticated"}}))
+		assert.Empty(t, config.exclusionReason("test", nil))
+	})
+
+	t.Run("should reject invalid exclusions", func(t *testing.T) {
+		for _, settings := range []map[string]interface{}{
+			{"exclude-namespaces": "monitoring-("},
+			{"exclude-service-accounts": "deployer"},
+			{"exclude-service-accounts": "cd/"},
+		} {
+			_, err := (&config{}).with(settings)
+			assert.Error(t, err, "%v", settings)
+		}
+	})
+
+	t.Run("should allow excluded requests without validation", func(t *testing.T) {
+		config := newConfig(map[string]interface{}{"exclude-namespaces": "kube-system", "exclude-users": "break-glass"})
+
+		ar := admissionReview("Pod", incompletePod)
+		ar.Request.Namespace = "test"
+		assert.False(t, validate(ar, config, nil).Allowed)
+
+		ar.Request.UserInfo.Username = "break-glass"
+		assert.True(t, validate(ar, config, nil).Allowed)
+
+		ar.Request.UserInfo.Username = "developer"
+		ar.Request.Namespace = "kube-system"
+		assert.True(t, validate(ar, config, nil).Allowed)
+	})
+}
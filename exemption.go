@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Rules an object is exempted from by its annotations, e.g.
+//
+//	<prefix>/exempt: "resource-limit-cpu-required,security-readonly-rootfs-required"
+//	<prefix>/exempt-reason: "Legacy image writing to /tmp, see JIRA-123"
+//	<prefix>/exempt-until: "2021-06-30"
+//
+// The reason is required, the exemption stops working after the until date.
+// Exemptions are ignored unless --exemptions-enabled is set, and only the
+// --exemptable-rules can be named.
+type exemption struct {
+	RuleIDs []string
+	Reason  string
+	// Nil if the exemption does not expire
+	Until *time.Time
+}
+
+// Returns the exemption of the object, nil if it has none. Objects with a pod
+// template are exempted by annotations of the pod (template), so that the
+// pods created from them are exempted as well.
+func objectExemption(object *admittedObject, config *config) (*exemption, error) {
+	if !config.ExemptionsEnabled {
+		return nil, nil
+	}
+	meta := object.ObjMeta
+	if object.PodTemplate != nil {
+		meta = object.PodTemplate.PodMeta
+	}
+	return parseExemption(meta.Annotations, config)
+}
+
+func parseExemption(annotations map[string]string, config *config) (*exemption, error) {
+	value, ok := annotations[prefixedAnnotation(config.AnnotationsPrefix, "exempt")]
+	if !ok {
+		return nil, nil
+	}
+	exemption := &exemption{Reason: strings.TrimSpace(annotations[prefixedAnnotation(config.AnnotationsPrefix, "exempt-reason")])}
+	for _, ruleID := range strings.Split(value, ",") {
+		ruleID = strings.TrimSpace(ruleID)
+		if ruleID == "" {
+			continue
+		}
+		if config.findRule(ruleID) == nil {
+			return nil, fmt.Errorf("Exemption from unknown rule '%s'", ruleID)
+		}
+		if !config.exemptable(ruleID) {
+			return nil, fmt.Errorf("Exemption from rule '%s' is not allowed, it is not among --exemptable-rules", ruleID)
+		}
+		exemption.RuleIDs = append(exemption.RuleIDs, ruleID)
+	}
+	if len(exemption.RuleIDs) == 0 {
+		return nil, fmt.Errorf("Exemption names no rules")
+	}
+	if exemption.Reason == "" {
+		return nil, fmt.Errorf("Exemption from %s has no reason, '%s' must be set",
+			strings.Join(exemption.RuleIDs, ", "), prefixedAnnotation(config.AnnotationsPrefix, "exempt-reason"))
+	}
+	if until, ok := annotations[prefixedAnnotation(config.AnnotationsPrefix, "exempt-until")]; ok {
+		untilTime, err := parseExemptionUntil(strings.TrimSpace(until))
+		if err != nil {
+			return nil, err
+		}
+		exemption.Until = &untilTime
+	}
+	return exemption, nil
+}
+
+// Accepts a date, valid until its end in UTC, or an RFC 3339 timestamp.
+func parseExemptionUntil(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date.AddDate(0, 0, 1), nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid exemption expiry '%s', expected a date like 2006-01-02 or an RFC 3339 time", value)
+	}
+	return until, nil
+}
+
+func (config *config) exemptable(ruleID string) bool {
+	for _, exemptable := range config.ExemptableRules {
+		if strings.TrimSpace(exemptable) == ruleID {
+			return true
+		}
+	}
+	return false
+}
+
+func (exemption *exemption) expired(now time.Time) bool {
+	return exemption.Until != nil && !now.Before(*exemption.Until)
+}
+
+func (exemption *exemption) String() string {
+	description := fmt.Sprintf("exempted from %s (%s)", strings.Join(exemption.RuleIDs, ", "), exemption.Reason)
+	if exemption.Until != nil {
+		description = fmt.Sprintf("%s until %s", description, exemption.Until.Format(time.RFC3339))
+	}
+	return description
+}
+
+// Removes the violations the object is exempted from and returns them along
+// with the exemption. An invalid exemption is returned as an error, leaving
+// the violations untouched.
+func exemptViolations(validation *objectValidation, object *admittedObject, config *config, now time.Time) (*exemption, *validationViolationSet, error) {
+	exemption, err := objectExemption(object, config)
+	if err != nil {
+		return nil, &validationViolationSet{}, err
+	}
+	var exempted *validationViolationSet
+	validation.Violations, exempted = validation.Violations.exempted(exemption, now)
+	return exemption, exempted, nil
+}
+
+// Splits violations to those the exemption does not cover and the exempted
+// ones. An expired exemption covers nothing.
+func (violationSet *validationViolationSet) exempted(exemption *exemption, now time.Time) (remaining *validationViolationSet, exempted *validationViolationSet) {
+	remaining, exempted = &validationViolationSet{}, &validationViolationSet{}
+	for _, v := range violationSet.Violations {
+		if exemption != nil && !exemption.expired(now) && exemption.covers(v.RuleID) {
+			exempted.add(v)
+		} else {
+			remaining.add(v)
+		}
+	}
+	return
+}
+
+func (exemption *exemption) covers(ruleID string) bool {
+	for _, exemptedRuleID := range exemption.RuleIDs {
+		if exemptedRuleID == ruleID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExemption(t *testing.T) {
+	initLogger()
+
+	config := &config{
+		AnnotationsPrefix: "admission.validation.avast.com",
+		ExemptionsEnabled: true,
+		ExemptableRules:   []string{"resource-limit-cpu-required", "security-readonly-rootfs-required", "ingress-collision"},
+	}
+	annotations := func(values ...string) map[string]string {
+		result := map[string]string{}
+		for i := 0; i < len(values); i += 2 {
+			result["admission.validation.avast.com/"+values[i]] = values[i+1]
+		}
+		return result
+	}
+
+	t.Run("should parse exemption", func(t *testing.T) {
+		exemption, err := parseExemption(annotations(
+			"exempt", "resource-limit-cpu-required, security-readonly-rootfs-required",
+			"exempt-reason", "Legacy app",
+			"exempt-until", "2021-06-30"), config)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resource-limit-cpu-required", "security-readonly-rootfs-required"}, exemption.RuleIDs)
+		assert.Equal(t, "Legacy app", exemption.Reason)
+		assert.False(t, exemption.expired(time.Date(2021, 6, 30, 23, 59, 0, 0, time.UTC)))
+		assert.True(t, exemption.expired(time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)))
+
+		exemption, err = parseExemption(annotations("exempt", "ingress-collision", "exempt-reason", "Migration", "exempt-until", "2021-06-30T12:00:00Z"), config)
+		assert.NoError(t, err)
+		assert.True(t, exemption.expired(time.Date(2021, 6, 30, 12, 0, 0, 0, time.UTC)))
+
+		exemption, err = parseExemption(annotations("exempt", "ingress-collision", "exempt-reason", "Migration"), config)
+		assert.NoError(t, err)
+		assert.False(t, exemption.expired(time.Now()))
+
+		exemption, err = parseExemption(annotations("exempt-reason", "Nothing to exempt from"), config)
+		assert.NoError(t, err)
+		assert.Nil(t, exemption)
+	})
+
+	t.Run("should reject invalid exemption", func(t *testing.T) {
+		for _, invalid := range []map[string]string{
+			annotations("exempt", "resource-limit-cpu-required"),
+			annotations("exempt", "resource-limit-cpu-required", "exempt-reason", " "),
+			annotations("exempt", "unknown-rule", "exempt-reason", "Legacy app"),
+			annotations("exempt", "security-privileged-forbidden", "exempt-reason", "Legacy app"),
+			annotations("exempt", "", "exempt-reason", "Legacy app"),
+			annotations("exempt", "resource-limit-cpu-required", "exempt-reason", "Legacy app", "exempt-until", "next week"),
+		} {
+			_, err := parseExemption(invalid, config)
+			assert.Error(t, err, "%v", invalid)
+		}
+	})
+
+	t.Run("should allow exempted violations only", func(t *testing.T) {
+		config, err := config.with(map[string]interface{}{
+			"rule-resource-limit-cpu-required":       true,
+			"rule-security-readonly-rootfs-required": true,
+		})
+		assert.NoError(t, err)
+		pod := func(annotations string) string {
+			return `{
+				"metadata": {"name": "pod", "namespace": "test", "annotations": {` + annotations + `}},
+				"spec": {"containers": [{"name": "container"}]}
+			}`
+		}
+
+		response := validate(admissionReview("Pod", pod(`
+			"admission.validation.avast.com/exempt": "resource-limit-cpu-required,security-readonly-rootfs-required",
+			"admission.validation.avast.com/exempt-reason": "Legacy app"`)), config, nil)
+		assert.True(t, response.Allowed)
+
+		response = validate(admissionReview("Pod", pod(`
+			"admission.validation.avast.com/exempt": "resource-limit-cpu-required",
+			"admission.validation.avast.com/exempt-reason": "Legacy app"`)), config, nil)
+		assert.False(t, response.Allowed)
+		assert.NotContains(t, response.Result.Message, "'cpu' resource limit must be specified")
+		assert.Contains(t, response.Result.Message, "'readOnlyRootFilesystem: true' must be specified")
+
+		response = validate(admissionReview("Pod", pod(`
+			"admission.validation.avast.com/exempt": "resource-limit-cpu-required,security-readonly-rootfs-required",
+			"admission.validation.avast.com/exempt-reason": "Legacy app",
+			"admission.validation.avast.com/exempt-until": "2020-01-01"`)), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Equal(t, []string{"Exemption expired on 2020-01-02T00:00:00Z"}, response.Warnings)
+
+		response = validate(admissionReview("Pod", pod(`
+			"admission.validation.avast.com/exempt": "resource-limit-cpu-required,security-readonly-rootfs-required"`)), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Len(t, response.Warnings, 1)
+		assert.Contains(t, response.Warnings[0], "Exemption ignored")
+	})
+
+	t.Run("should deny violations of rules which are not exemptable", func(t *testing.T) {
+		config, err := config.with(map[string]interface{}{
+			"rule-resource-limit-cpu-required":   true,
+			"rule-security-privileged-forbidden": true,
+		})
+		assert.NoError(t, err)
+		response := validate(admissionReview("Pod", `{
+			"metadata": {"name": "pod", "namespace": "test", "annotations": {
+				"admission.validation.avast.com/exempt": "resource-limit-cpu-required,security-privileged-forbidden",
+				"admission.validation.avast.com/exempt-reason": "Debugging"}},
+			"spec": {"containers": [{"name": "container", "securityContext": {"privileged": true}}]}
+		}`), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Contains(t, response.Result.Message, "'privileged: true' is not allowed")
+		assert.Contains(t, response.Result.Message, "'cpu' resource limit must be specified")
+		assert.Len(t, response.Warnings, 1)
+		assert.Contains(t, response.Warnings[0], "Exemption from rule 'security-privileged-forbidden' is not allowed")
+	})
+
+	t.Run("should ignore exemptions unless enabled", func(t *testing.T) {
+		config, err := config.with(map[string]interface{}{"exemptions-enabled": false, "rule-resource-limit-cpu-required": true})
+		assert.NoError(t, err)
+		response := validate(admissionReview("Pod", `{
+			"metadata": {"name": "pod", "namespace": "test", "annotations": {
+				"admission.validation.avast.com/exempt": "resource-limit-cpu-required",
+				"admission.validation.avast.com/exempt-reason": "Legacy app"}},
+			"spec": {"containers": [{"name": "container"}]}
+		}`), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Empty(t, response.Warnings)
+	})
+
+	t.Run("should reject unknown exemptable rules", func(t *testing.T) {
+		_, err := config.with(map[string]interface{}{"exemptable-rules": "unknown-rule"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const readinessCheckTimeout = 5 * time.Second
+
+type readinessCheck struct {
+	Name  string
+	Check func() error
+}
+
+type readinessChecks []readinessCheck
+
+// The process is alive as long as it is able to answer.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "ok")
+}
+
+// Ready only if all the checks pass, otherwise answers 503 listing the failed ones.
+func (checks readinessChecks) readyz(w http.ResponseWriter, r *http.Request) {
+	var failures []string
+	for _, check := range checks {
+		if err := check.Check(); err != nil {
+			log.Warnf("Readiness check '%s' failed: %v", check.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", check.Name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, failure := range failures {
+			fmt.Fprintln(w, failure)
+		}
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+func (checks readinessChecks) register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", checks.readyz)
+}
+
+// Passes if the serving certificate is loaded and currently valid.
+func certificateCheck(certificate func() *tls.Certificate) readinessCheck {
+	return readinessCheck{"tls", func() error {
+		cert := certificate()
+		if cert == nil || len(cert.Certificate) == 0 {
+			return fmt.Errorf("no certificate loaded")
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			return fmt.Errorf("certificate is valid only from %v to %v", leaf.NotBefore, leaf.NotAfter)
+		}
+		return nil
+	}}
+}
+
+// Passes if ingresses, needed by the ingress collision rule, can be listed.
+// Rules can be enabled by the policy file, its profiles or namespace overrides,
+// so the check applies whenever the current config registers ingresses.
+func ingressListCheck(clientSet kubernetes.Interface, configs *configStore) readinessCheck {
+	return readinessCheck{"kubernetes-api", func() error {
+		kinds, err := configs.get().registeredKinds()
+		if err != nil {
+			return err
+		}
+		registered := false
+		for _, kind := range kinds {
+			registered = registered || kind == "Ingress"
+		}
+		if !registered {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), readinessCheckTimeout)
+		defer cancel()
+		_, err = IngressClientAllNamespaces(clientSet).List(ctx, metav1.ListOptions{Limit: 1})
+		return err
+	}}
+}
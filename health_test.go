@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestHealth(t *testing.T) {
+	initLogger()
+
+	serveReadyz := func(checks readinessChecks) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		checks.readyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return recorder
+	}
+
+	t.Run("should be ready when all checks pass", func(t *testing.T) {
+		recorder := serveReadyz(readinessChecks{{"first", func() error { return nil }}})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("should not be ready when any check fails", func(t *testing.T) {
+		recorder := serveReadyz(readinessChecks{
+			{"first", func() error { return nil }},
+			{"second", func() error { return errors.New("unreachable") }},
+		})
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+		assert.Equal(t, "second: unreachable\n", recorder.Body.String())
+	})
+
+	t.Run("should not be ready without certificate", func(t *testing.T) {
+		recorder := serveReadyz(readinessChecks{certificateCheck(func() *tls.Certificate { return nil })})
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	})
+
+	t.Run("should check listing ingresses when the current config registers them", func(t *testing.T) {
+		clientSet := fake.NewSimpleClientset()
+		clientSet.PrependReactor("list", "ingresses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("forbidden")
+		})
+		configs := &configStore{config: &config{}}
+		check := ingressListCheck(clientSet, configs)
+		assert.NoError(t, check.Check())
+
+		policyConfig, err := (&config{}).withPolicyData([]byte("rules:\n  ingress-collision: {}\n"))
+		assert.NoError(t, err)
+		configs.set(policyConfig)
+		assert.EqualError(t, check.Check(), "forbidden")
+	})
+}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -26,7 +27,7 @@ func TestClusterCollisions(t *testing.T) {
 	assert.NoError(t, err)
 
 	t.Run("Cross cluster validation", func(t *testing.T) {
-		remoteIngresses, err := IngressClientAllNamespaces(kubeClientSet).List(metav1.ListOptions{})
+		remoteIngresses, err := IngressClientAllNamespaces(kubeClientSet).List(context.TODO(), metav1.ListOptions{})
 		if assert.Nil(t, err) {
 			for _, ingress := range remoteIngresses.Items {
 				log.Debugf("Processing ingress %s", ingress.Name)
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -126,3 +127,39 @@ func TestIngress(t *testing.T) {
 		})
 	})
 }
+
+func TestIngressFields(t *testing.T) {
+	initLogger()
+	ingress := &extv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultName, Namespace: defaultNamespace},
+		Spec: extv1beta1.IngressSpec{
+			TLS: []extv1beta1.IngressTLS{{Hosts: []string{"service1.avast.com", "service*.avast.com"}}},
+			Rules: []extv1beta1.IngressRule{{
+				Host: "service1.avast.com",
+				IngressRuleValue: extv1beta1.IngressRuleValue{HTTP: &extv1beta1.HTTPIngressRuleValue{
+					Paths: []extv1beta1.HTTPIngressPath{{Path: "/"}, {Path: "/${}"}},
+				}},
+			}},
+		},
+	}
+
+	t.Run("should point violations at the fields of the ingress", func(t *testing.T) {
+		validation := &objectValidation{"Ingress", &metav1.ObjectMeta{}, &validationViolationSet{}}
+		pathData, pathFields := ingressPath(ingress)
+		ValidatePathDataRegex(pathData, validation, targetDescription, pathFields...)
+		tlsData, tlsFields := ingressTls(ingress)
+		ValidateTlsDataRegex(tlsData, validation, targetDescription, tlsFields...)
+
+		assert.Len(t, validation.Violations.Violations, 2)
+		assert.Equal(t, "spec.rules[0].http.paths[1].path", validation.Violations.Violations[0].Field)
+		assert.Equal(t, "spec.tls[0].hosts[1]", validation.Violations.Violations[1].Field)
+	})
+
+	t.Run("should point collisions at the fields of the ingress", func(t *testing.T) {
+		validation := &objectValidation{"Ingress", &metav1.ObjectMeta{}, &validationViolationSet{}}
+		pathData, pathFields := ingressPath(ingress)
+		ValidatePathDataCollision(pathData, collisionPaths, validation, targetDescription, pathFields...)
+		assert.Len(t, validation.Violations.Violations, 1)
+		assert.Equal(t, "spec.rules[0].http.paths[0].path", validation.Violations.Violations[0].Field)
+	})
+}
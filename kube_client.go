@@ -11,11 +11,11 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func IngressClientAllNamespaces(clientset *kubernetes.Clientset) v1beta1.IngressInterface {
+func IngressClientAllNamespaces(clientset kubernetes.Interface) v1beta1.IngressInterface {
 	return IngressClient(metav1.NamespaceAll, clientset)
 }
 
-func IngressClient(namespace string, clientset *kubernetes.Clientset) (ingresses v1beta1.IngressInterface) {
+func IngressClient(namespace string, clientset kubernetes.Interface) (ingresses v1beta1.IngressInterface) {
 	ingresses = clientset.ExtensionsV1beta1().Ingresses(namespace)
 	return
 }
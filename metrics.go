@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "k8s_admission_webhook"
+
+var (
+	admissionRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "validation_requests_total",
+			Help:      "Number of validated objects by kind, namespace, operation and decision ('allowed', 'denied', 'audited' when audit mode allowed an object that would have been denied, or 'excluded' when not validated at all).",
+		},
+		[]string{"kind", "namespace", "operation", "decision"},
+	)
+	ruleViolations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "rule_violations_total",
+			Help:      "Number of rule violations found by rule and its enforcement level.",
+		},
+		[]string{"rule", "enforcement"},
+	)
+	decodeErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "decode_errors_total",
+			Help:      "Number of admission reviews or admitted objects which could not be decoded, by kind.",
+		},
+		[]string{"kind"},
+	)
+	validationDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "validation_duration_seconds",
+			Help:      "Time spent validating an admitted object.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+	)
+	tlsCertificateExpiry = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tls_certificate_expiry_timestamp_seconds",
+			Help:      "Expiry of the currently served TLS certificate as a Unix timestamp.",
+		},
+	)
+	ingressListDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "ingress_list_duration_seconds",
+			Help:      "Time spent listing cluster ingresses for the ingress collision check.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(admissionRequests, ruleViolations, decodeErrors, validationDuration, tlsCertificateExpiry, ingressListDuration)
+}
+
+func recordViolations(violationSet *validationViolationSet, enforcement enforcementLevel) {
+	for _, v := range violationSet.Violations {
+		ruleViolations.WithLabelValues(v.RuleID, string(enforcement)).Inc()
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type resourceDefaults struct {
+	Limits   corev1.ResourceList
+	Requests corev1.ResourceList
+}
+
+func mutate(ar admissionv1.AdmissionReview, config *config, clientSet *kubernetes.Clientset) *admissionv1.AdmissionResponse {
+	reviewResponse := admissionv1.AdmissionResponse{Allowed: true}
+
+	template, err := decodePodTemplate(ar.Request.Kind.Kind, ar.Request.Object.Raw)
+	if err != nil {
+		log.Error(err)
+		return toAdmissionResponse(err)
+	}
+	if template == nil {
+		return &reviewResponse
+	}
+
+	defaults, err := config.resourceDefaults(namespaceAnnotations(ar.Request.Namespace, config), config.AnnotationsPrefix)
+	if err != nil {
+		// config defaults are checked on startup, so this can only be a broken namespace annotation
+		log.Warnf("Ignoring resource defaults of namespace '%s': %v", ar.Request.Namespace, err)
+		defaults, _ = config.resourceDefaults(nil, config.AnnotationsPrefix)
+	}
+
+	patch := resourceDefaultsPatch(template, defaults)
+	if len(patch) > 0 {
+		patchBytes, err := json.Marshal(patch)
+		if err != nil {
+			log.Error(err)
+			return toAdmissionResponse(err)
+		}
+		log.Debugf("Patching %s '%s/%s': %s", ar.Request.Kind.Kind, ar.Request.Namespace, template.ObjMeta.GetName(), patchBytes)
+		patchType := admissionv1.PatchTypeJSONPatch
+		reviewResponse.Patch = patchBytes
+		reviewResponse.PatchType = &patchType
+	}
+
+	return &reviewResponse
+}
+
+// Returns JSONPatch operations filling in resource requests and limits missing
+// in containers and init containers of the pod template.
+func resourceDefaultsPatch(template *podTemplate, defaults *resourceDefaults) []jsonPatchOperation {
+	var patch []jsonPatchOperation
+	specPath := "/" + strings.Replace(template.SpecPath, ".", "/", -1)
+	for i, container := range template.PodSpec.Containers {
+		patch = append(patch, containerResourcesPatch(fmt.Sprintf("%s/containers/%d", specPath, i), &container, defaults)...)
+	}
+	for i, container := range template.PodSpec.InitContainers {
+		patch = append(patch, containerResourcesPatch(fmt.Sprintf("%s/initContainers/%d", specPath, i), &container, defaults)...)
+	}
+	return patch
+}
+
+func containerResourcesPatch(containerPath string, container *corev1.Container, defaults *resourceDefaults) []jsonPatchOperation {
+	limits := container.Resources.Limits.DeepCopy()
+	if limits == nil {
+		limits = corev1.ResourceList{}
+	}
+	requests := container.Resources.Requests.DeepCopy()
+	if requests == nil {
+		requests = corev1.ResourceList{}
+	}
+
+	// defaults must not produce a request exceeding its limit
+	var missingLimits, missingRequests []corev1.ResourceName
+	for _, name := range sortedResourceNames(defaults.Limits) {
+		if _, ok := limits[name]; !ok {
+			value := defaults.Limits[name]
+			if request, ok := requests[name]; ok && request.Cmp(value) > 0 {
+				value = request
+			}
+			limits[name] = value
+			missingLimits = append(missingLimits, name)
+		}
+	}
+	for _, name := range sortedResourceNames(defaults.Requests) {
+		if _, ok := requests[name]; !ok {
+			value := defaults.Requests[name]
+			if limit, ok := limits[name]; ok && limit.Cmp(value) < 0 {
+				value = limit
+			}
+			requests[name] = value
+			missingRequests = append(missingRequests, name)
+		}
+	}
+
+	if len(missingLimits) == 0 && len(missingRequests) == 0 {
+		return nil
+	}
+
+	// the "resources" member might not be present at all, so it is replaced as a whole
+	if container.Resources.Limits == nil && container.Resources.Requests == nil {
+		resources := corev1.ResourceRequirements{}
+		if len(limits) > 0 {
+			resources.Limits = limits
+		}
+		if len(requests) > 0 {
+			resources.Requests = requests
+		}
+		return []jsonPatchOperation{{"add", containerPath + "/resources", resources}}
+	}
+
+	var patch []jsonPatchOperation
+	patch = append(patch, resourceListPatch(containerPath+"/resources/limits", container.Resources.Limits, limits, missingLimits)...)
+	patch = append(patch, resourceListPatch(containerPath+"/resources/requests", container.Resources.Requests, requests, missingRequests)...)
+	return patch
+}
+
+func resourceListPatch(listPath string, original corev1.ResourceList, defaulted corev1.ResourceList, missing []corev1.ResourceName) []jsonPatchOperation {
+	if len(missing) == 0 {
+		return nil
+	}
+	if original == nil {
+		return []jsonPatchOperation{{"add", listPath, defaulted}}
+	}
+	var patch []jsonPatchOperation
+	for _, name := range missing {
+		patch = append(patch, jsonPatchOperation{"add", listPath + "/" + escapeJSONPointer(string(name)), defaulted[name]})
+	}
+	return patch
+}
+
+// Resource defaults taken from the configuration, overridden by annotations of
+// the target namespace.
+func (config *config) resourceDefaults(annotations map[string]string, annotationsPrefix string) (*resourceDefaults, error) {
+	defaults := &resourceDefaults{corev1.ResourceList{}, corev1.ResourceList{}}
+	values := []struct {
+		key   string
+		value string
+		list  corev1.ResourceList
+		name  corev1.ResourceName
+	}{
+		{"default-resource-limit-cpu", config.DefaultResourceLimitCPU, defaults.Limits, corev1.ResourceCPU},
+		{"default-resource-limit-memory", config.DefaultResourceLimitMemory, defaults.Limits, corev1.ResourceMemory},
+		{"default-resource-request-cpu", config.DefaultResourceRequestCPU, defaults.Requests, corev1.ResourceCPU},
+		{"default-resource-request-memory", config.DefaultResourceRequestMemory, defaults.Requests, corev1.ResourceMemory},
+	}
+
+	for _, v := range values {
+		value := v.value
+		if annotationValue, ok := annotations[prefixedAnnotation(annotationsPrefix, v.key)]; ok {
+			value = annotationValue
+		}
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value '%s' of '%s': %v", value, v.key, err)
+		}
+		v.list[v.name] = quantity
+	}
+
+	return defaults, nil
+}
+
+func namespaceAnnotations(namespace string, config *config) map[string]string {
+	meta, err := config.namespaces.get(namespace)
+	if err != nil {
+		log.Warnf("Could not get namespace '%s': %v", namespace, err)
+		return nil
+	}
+	if meta == nil {
+		return nil
+	}
+	return meta.Annotations
+}
+
+func sortedResourceNames(resList corev1.ResourceList) []corev1.ResourceName {
+	var names []corev1.ResourceName
+	for name := range resList {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var mutationConfig = &config{
+	DefaultResourceLimitCPU:      "1",
+	DefaultResourceLimitMemory:   "512Mi",
+	DefaultResourceRequestCPU:    "100m",
+	DefaultResourceRequestMemory: "128Mi",
+	AnnotationsPrefix:            "admission.validation.avast.com",
+}
+
+func mutationPatch(t *testing.T, kind string, object string) []jsonPatchOperation {
+	response := mutate(admissionReview(kind, object), mutationConfig, nil)
+	assert.True(t, response.Allowed)
+
+	var patch []jsonPatchOperation
+	if response.Patch != nil {
+		assert.Equal(t, admissionv1.PatchTypeJSONPatch, *response.PatchType)
+		assert.NoError(t, json.Unmarshal(response.Patch, &patch))
+	}
+	return patch
+}
+
+func TestMutation(t *testing.T) {
+	initLogger()
+
+	t.Run("should add whole resources to container without any", func(t *testing.T) {
+		patch := mutationPatch(t, "Deployment", `{"spec": {"template": {"spec": {"containers": [{"name": "c"}]}}}}`)
+		if assert.Len(t, patch, 1) {
+			assert.Equal(t, "add", patch[0].Op)
+			assert.Equal(t, "/spec/template/spec/containers/0/resources", patch[0].Path)
+			assert.Equal(t, map[string]interface{}{
+				"limits":   map[string]interface{}{"cpu": "1", "memory": "512Mi"},
+				"requests": map[string]interface{}{"cpu": "100m", "memory": "128Mi"},
+			}, patch[0].Value)
+		}
+	})
+
+	t.Run("should add only missing resources", func(t *testing.T) {
+		patch := mutationPatch(t, "CronJob", `{"spec": {"jobTemplate": {"spec": {"template": {"spec": {"initContainers": [
+			{"name": "c", "resources": {"limits": {"cpu": "2"}}}
+		]}}}}}}`)
+		assert.Equal(t, []jsonPatchOperation{
+			{"add", "/spec/jobTemplate/spec/template/spec/initContainers/0/resources/limits/memory", "512Mi"},
+			{"add", "/spec/jobTemplate/spec/template/spec/initContainers/0/resources/requests", map[string]interface{}{"cpu": "100m", "memory": "128Mi"}},
+		}, patch)
+	})
+
+	t.Run("should not default request above an existing limit", func(t *testing.T) {
+		patch := mutationPatch(t, "Pod", `{"spec": {"containers": [
+			{"name": "c", "resources": {"limits": {"cpu": "50m", "memory": "64Mi"}, "requests": {"memory": "64Mi"}}}
+		]}}`)
+		assert.Equal(t, []jsonPatchOperation{
+			{"add", "/spec/containers/0/resources/requests/cpu", "50m"},
+		}, patch)
+	})
+
+	t.Run("should not patch complete containers and kinds without pods", func(t *testing.T) {
+		assert.Empty(t, mutationPatch(t, "Pod", `{"spec": {"containers": [
+			{"name": "c", "resources": {"limits": {"cpu": "1", "memory": "1Gi"}, "requests": {"cpu": "1", "memory": "1Gi"}}}
+		]}}`))
+		assert.Empty(t, mutationPatch(t, "Ingress", `{"spec": {}}`))
+	})
+
+	t.Run("should prefer namespace annotations", func(t *testing.T) {
+		defaults, err := mutationConfig.resourceDefaults(map[string]string{
+			"admission.validation.avast.com/default-resource-limit-cpu": "4",
+		}, mutationConfig.AnnotationsPrefix)
+		if assert.NoError(t, err) {
+			assert.Equal(t, resource.MustParse("4"), defaults.Limits[corev1.ResourceCPU])
+			assert.Equal(t, resource.MustParse("512Mi"), defaults.Limits[corev1.ResourceMemory])
+		}
+		_, err = mutationConfig.resourceDefaults(map[string]string{
+			"admission.validation.avast.com/default-resource-limit-cpu": "lots",
+		}, mutationConfig.AnnotationsPrefix)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Keeps namespace metadata for a while, as it is looked up for every admitted
+// object.
+type namespaceCache struct {
+	clientSet kubernetes.Interface
+	// zero keeps the namespaces forever, e.g. for a single scan
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]namespaceCacheEntry
+}
+
+type namespaceCacheEntry struct {
+	meta    *metav1.ObjectMeta
+	expires time.Time
+}
+
+func newNamespaceCache(clientSet kubernetes.Interface, ttl time.Duration) *namespaceCache {
+	return &namespaceCache{clientSet: clientSet, ttl: ttl, entries: map[string]namespaceCacheEntry{}}
+}
+
+// Returns nil for cluster-scoped objects (empty name) or when there is no cache.
+func (cache *namespaceCache) get(name string) (*metav1.ObjectMeta, error) {
+	if cache == nil || name == "" {
+		return nil, nil
+	}
+
+	now := time.Now()
+	cache.mu.Lock()
+	entry, ok := cache.entries[name]
+	cache.mu.Unlock()
+	if ok && (cache.ttl <= 0 || now.Before(entry.expires)) {
+		return entry.meta, nil
+	}
+
+	namespace, err := cache.clientSet.CoreV1().Namespaces().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.entries[name] = namespaceCacheEntry{&namespace.ObjectMeta, now.Add(cache.ttl)}
+	cache.mu.Unlock()
+	return &namespace.ObjectMeta, nil
+}
+
+// Keeps the configs derived for namespaces until their resourceVersion
+// changes. It belongs to a single config, so a reloaded policy starts empty.
+type namespaceConfigCache struct {
+	mu      sync.Mutex
+	entries map[string]namespaceConfigCacheEntry
+}
+
+type namespaceConfigCacheEntry struct {
+	resourceVersion string
+	config          *config
+}
+
+func newNamespaceConfigCache() *namespaceConfigCache {
+	return &namespaceConfigCache{entries: map[string]namespaceConfigCacheEntry{}}
+}
+
+// Returns nil unless the config was derived from the same version of the
+// namespace.
+func (cache *namespaceConfigCache) get(meta *metav1.ObjectMeta) *config {
+	if cache == nil || meta.ResourceVersion == "" {
+		return nil
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if entry, ok := cache.entries[meta.Name]; ok && entry.resourceVersion == meta.ResourceVersion {
+		return entry.config
+	}
+	return nil
+}
+
+func (cache *namespaceConfigCache) set(meta *metav1.ObjectMeta, config *config) {
+	if cache == nil || meta.ResourceVersion == "" {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.entries[meta.Name] = namespaceConfigCacheEntry{meta.ResourceVersion, config}
+}
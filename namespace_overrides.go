@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Label of a namespace selecting one of the profiles of the policy file.
+const policyProfileLabel = "policy-profile"
+
+// Returns the config to validate objects of the namespace with. When
+// --namespace-overrides is set, the namespace can select a policy profile by
+// its label and override rule settings by annotations named like the flags,
+// e.g. '<prefix>/rule-resource-limit-cpu-required: "false"'. If the
+// namespace cannot be read or its overrides are invalid, the config is used as
+// it is. Derived configs are kept until the namespace changes.
+func (config *config) forNamespace(namespace string) *config {
+	if !config.NamespaceOverrides {
+		return config
+	}
+	meta, err := config.namespaces.get(namespace)
+	if err != nil {
+		log.Warnf("Could not get namespace '%s', its overrides are ignored: %v", namespace, err)
+		return config
+	}
+	if meta == nil {
+		return config
+	}
+	if cached := config.namespaceConfigs.get(meta); cached != nil {
+		return cached
+	}
+	result, err := config.withNamespaceOverrides(meta)
+	if err != nil {
+		log.Warnf("Invalid overrides of namespace '%s' are ignored: %v", namespace, err)
+		return config
+	}
+	config.namespaceConfigs.set(meta, result)
+	return result
+}
+
+func (config *config) withNamespaceOverrides(meta *metav1.ObjectMeta) (*config, error) {
+	result := config
+	if profileName, ok := meta.Labels[policyProfileLabel]; ok {
+		profile, ok := config.profiles[profileName]
+		if !ok {
+			return nil, fmt.Errorf("Unknown policy profile '%s'", profileName)
+		}
+		var err error
+		if result, err = result.withPolicy(profile); err != nil {
+			return nil, fmt.Errorf("Policy profile '%s': %v", profileName, err)
+		}
+	}
+
+	settings := map[string]interface{}{}
+	for annotation, value := range meta.Annotations {
+		key := strings.TrimPrefix(annotation, config.AnnotationsPrefix+"/")
+		if key == annotation || !strings.HasPrefix(key, "rule-") {
+			continue
+		}
+		if !isConfigKey(key) {
+			return nil, fmt.Errorf("Unknown setting in annotation '%s'", annotation)
+		}
+		settings[key] = value
+	}
+	if len(settings) == 0 {
+		return result, nil
+	}
+	if enforcement, ok := settings["rule-enforcement"].(string); ok {
+		settings["rule-enforcement"] = append(append([]string{}, result.RuleEnforcement...), strings.Split(enforcement, ",")...)
+	}
+	return result.with(settings)
+}
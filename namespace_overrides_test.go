@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceOverrides(t *testing.T) {
+	initLogger()
+
+	newConfig := func(namespaces ...*corev1.Namespace) *config {
+		flags := &config{NamespaceOverrides: true, AnnotationsPrefix: "admission.validation.avast.com"}
+		assert.NoError(t, flags.init())
+		config, err := flags.withPolicyData([]byte(`
+rules:
+  resource-limit-cpu-required: {}
+profiles:
+  strict:
+    rules:
+      security-readonly-rootfs-required: {}
+`))
+		assert.NoError(t, err)
+
+		clientSet := fake.NewSimpleClientset()
+		for _, namespace := range namespaces {
+			_, err := clientSet.CoreV1().Namespaces().Create(context.TODO(), namespace, metav1.CreateOptions{})
+			assert.NoError(t, err)
+		}
+		config.namespaces = newNamespaceCache(clientSet, time.Minute)
+		return config
+	}
+	namespace := func(name string, labels map[string]string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels, Annotations: annotations}}
+	}
+	review := func(namespace string) admissionv1.AdmissionReview {
+		ar := admissionReview("Pod", incompletePod)
+		ar.Request.Namespace = namespace
+		return ar
+	}
+
+	t.Run("should disable a rule by namespace annotation", func(t *testing.T) {
+		config := newConfig(namespace("relaxed", nil, map[string]string{
+			"admission.validation.avast.com/rule-resource-limit-cpu-required": "false",
+		}))
+		assert.True(t, validate(review("relaxed"), config, nil).Allowed)
+		assert.False(t, validate(review("default"), config, nil).Allowed, "unknown namespaces keep the config")
+	})
+
+	t.Run("should change enforcement by namespace annotation", func(t *testing.T) {
+		config := newConfig(namespace("warned", nil, map[string]string{
+			"admission.validation.avast.com/rule-enforcement": "resource-limit-cpu-required=warn",
+		}))
+		response := validate(review("warned"), config, nil)
+		assert.True(t, response.Allowed)
+		assert.NotEmpty(t, response.Warnings)
+	})
+
+	t.Run("should apply the profile selected by namespace label", func(t *testing.T) {
+		config := newConfig(
+			namespace("strict", map[string]string{"policy-profile": "strict"}, map[string]string{
+				"admission.validation.avast.com/rule-resource-limit-cpu-required": "false",
+			}),
+		)
+		response := validate(review("strict"), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Contains(t, response.Result.Message, "'readOnlyRootFilesystem: true' must be specified")
+		assert.NotContains(t, response.Result.Message, "'cpu' resource limit must be specified")
+	})
+
+	t.Run("should reuse configs until the namespace changes", func(t *testing.T) {
+		relaxed := namespace("relaxed", nil, map[string]string{
+			"admission.validation.avast.com/rule-resource-limit-cpu-required": "false",
+		})
+		relaxed.ResourceVersion = "1"
+		config := newConfig(relaxed)
+		derived := config.forNamespace("relaxed")
+		assert.False(t, config == derived)
+		assert.True(t, derived == config.forNamespace("relaxed"))
+
+		config.namespaces.entries["relaxed"] = namespaceCacheEntry{&metav1.ObjectMeta{Name: "relaxed", ResourceVersion: "2"}, time.Now().Add(time.Minute)}
+		assert.False(t, derived == config.forNamespace("relaxed"))
+	})
+
+	t.Run("should drop derived configs when the policy reloads", func(t *testing.T) {
+		relaxed := namespace("relaxed", nil, map[string]string{
+			"admission.validation.avast.com/rule-resource-limit-cpu-required": "false",
+		})
+		relaxed.ResourceVersion = "1"
+		config := newConfig(relaxed)
+		config.forNamespace("relaxed")
+		assert.Len(t, config.namespaceConfigs.entries, 1)
+
+		reloaded, err := config.withPolicyData([]byte("rules: {}\n"))
+		assert.NoError(t, err)
+		assert.Empty(t, reloaded.namespaceConfigs.entries)
+	})
+
+	t.Run("should ignore invalid overrides", func(t *testing.T) {
+		config := newConfig(
+			namespace("unknown-profile", map[string]string{"policy-profile": "lax"}, nil),
+			namespace("unknown-setting", nil, map[string]string{"admission.validation.avast.com/rule-unknown": "true"}),
+		)
+		assert.True(t, config == config.forNamespace("unknown-profile"))
+		assert.True(t, config == config.forNamespace("unknown-setting"))
+	})
+
+	t.Run("should ignore overrides unless enabled", func(t *testing.T) {
+		config := newConfig(namespace("relaxed", nil, map[string]string{
+			"admission.validation.avast.com/rule-resource-limit-cpu-required": "false",
+		}))
+		config.NamespaceOverrides = false
+		assert.False(t, validate(review("relaxed"), config, nil).Allowed)
+	})
+
+	t.Run("should reject invalid profiles on load", func(t *testing.T) {
+		_, err := (&config{}).withPolicyData([]byte("profiles:\n  strict:\n    rules:\n      unknown-rule: {}\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should cache namespaces", func(t *testing.T) {
+		clientSet := fake.NewSimpleClientset(namespace("cached", map[string]string{"team": "a"}, nil))
+		cache := newNamespaceCache(clientSet, time.Minute)
+		meta, err := cache.get("cached")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", meta.Labels["team"])
+
+		assert.NoError(t, clientSet.CoreV1().Namespaces().Delete(context.TODO(), "cached", metav1.DeleteOptions{}))
+		meta, err = cache.get("cached")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", meta.Labels["team"])
+
+		cache.entries["cached"] = namespaceCacheEntry{meta, time.Now().Add(-time.Second)}
+		_, err = cache.get("cached")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podTemplate points into an admitted object carrying a pod specification.
+type podTemplate struct {
+	ObjMeta *metav1.ObjectMeta
+	PodMeta *metav1.ObjectMeta
+	PodSpec *corev1.PodSpec
+	// SpecPath is the path of the pod spec within the object, e.g. "spec.template.spec"
+	SpecPath string
+}
+
+// Decodes an object of given kind and returns its pod template. Returns nil
+// for kinds that do not carry any pod specification.
+func decodePodTemplate(kind string, raw []byte) (*podTemplate, error) {
+	deserializer := codecs.UniversalDeserializer()
+
+	switch kind {
+	case "Pod":
+		pod := corev1.Pod{}
+		if _, _, err := deserializer.Decode(raw, nil, &pod); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&pod.ObjectMeta, &pod.ObjectMeta, &pod.Spec, "spec"}, nil
+
+	case "ReplicaSet":
+		replicaSet := appsv1.ReplicaSet{}
+		if _, _, err := deserializer.Decode(raw, nil, &replicaSet); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&replicaSet.ObjectMeta, &replicaSet.Spec.Template.ObjectMeta, &replicaSet.Spec.Template.Spec, "spec.template.spec"}, nil
+
+	case "Deployment":
+		deployment := appsv1.Deployment{}
+		if _, _, err := deserializer.Decode(raw, nil, &deployment); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&deployment.ObjectMeta, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec, "spec.template.spec"}, nil
+
+	case "DaemonSet":
+		daemonSet := appsv1.DaemonSet{}
+		if _, _, err := deserializer.Decode(raw, nil, &daemonSet); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&daemonSet.ObjectMeta, &daemonSet.Spec.Template.ObjectMeta, &daemonSet.Spec.Template.Spec, "spec.template.spec"}, nil
+
+	case "Job":
+		job := batchv1.Job{}
+		if _, _, err := deserializer.Decode(raw, nil, &job); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&job.ObjectMeta, &job.Spec.Template.ObjectMeta, &job.Spec.Template.Spec, "spec.template.spec"}, nil
+
+	case "CronJob":
+		cronJob := batchv1beta1.CronJob{}
+		if _, _, err := deserializer.Decode(raw, nil, &cronJob); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&cronJob.ObjectMeta, &cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, &cronJob.Spec.JobTemplate.Spec.Template.Spec, "spec.jobTemplate.spec.template.spec"}, nil
+
+	case "StatefulSet":
+		statefulSet := appsv1.StatefulSet{}
+		if _, _, err := deserializer.Decode(raw, nil, &statefulSet); err != nil {
+			return nil, err
+		}
+		return &podTemplate{&statefulSet.ObjectMeta, &statefulSet.Spec.Template.ObjectMeta, &statefulSet.Spec.Template.Spec, "spec.template.spec"}, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// Structured alternative to the rule flags, e.g.
+//
+//	rules:
+//	  resource-limit-cpu-required:
+//	    enforcement: warn
+//	  security-readonly-rootfs-required:
+//	    parameters:
+//	      whitelist-enabled: true
+//	messages:
+//	  resource: "See https://wiki.example.com/resources"
+//
+//	profiles:
+//	  strict:
+//	    rules:
+//	      resource-limit-memory-required: {}
+//
+// A listed rule is enabled unless it sets 'enabled: false'. Its parameters are
+// the rule flags with the rule ID as prefix. Profiles are policies applied on
+// top of it for namespaces selecting them.
+type policy struct {
+	Rules    map[string]policyRule `json:"rules"`
+	Messages policyMessages        `json:"messages"`
+	Profiles map[string]*policy    `json:"profiles"`
+	CELRules []policyCELRule       `json:"celRules"`
+}
+
+type policyRule struct {
+	Enabled     *bool                  `json:"enabled"`
+	Enforcement string                 `json:"enforcement"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+type policyMessages struct {
+	Resource string `json:"resource"`
+	Ingress  string `json:"ingress"`
+}
+
+// Parses a YAML or JSON policy, unknown fields are rejected.
+func parsePolicy(data []byte) (*policy, error) {
+	policy := &policy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Returns the settings of the policy keyed like the flags, for rules known to
+// the config.
+func (policy *policy) settings(config *config) (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	var enforcement []string
+	for ruleID, rule := range policy.Rules {
+		registered := config.findRule(ruleID)
+		if registered == nil {
+			return nil, fmt.Errorf("Unknown rule '%s'", ruleID)
+		}
+		if _, ok := registered.(*celRule); ok {
+			// CEL rules are enabled by being defined, disabling switches them off
+			if rule.Enabled != nil && !*rule.Enabled {
+				enforcement = append(enforcement, fmt.Sprintf("%s=%s", ruleID, enforcementOff))
+			}
+		} else {
+			settings["rule-"+ruleID] = rule.Enabled == nil || *rule.Enabled
+		}
+		for name, value := range rule.Parameters {
+			if ruleParameterByName(registered, name) == nil {
+				return nil, fmt.Errorf("Unknown parameter '%s' of rule '%s'", name, ruleID)
+			}
+			settings[fmt.Sprintf("rule-%s-%s", ruleID, name)] = value
+		}
+		if rule.Enforcement != "" {
+			enforcement = append(enforcement, fmt.Sprintf("%s=%s", ruleID, rule.Enforcement))
+		}
+	}
+	if enforcement != nil {
+		settings["rule-enforcement"] = enforcement
+	}
+	if policy.Messages.Resource != "" {
+		settings["rule-resource-violation-message"] = policy.Messages.Resource
+	}
+	if policy.Messages.Ingress != "" {
+		settings["rule-ingress-violation-message"] = policy.Messages.Ingress
+	}
+	return settings, nil
+}
+
+// Returns a copy of the config with the policy applied on top of it. Rule
+// enforcement levels of the policy are added to those of the config.
+func (config *config) withPolicy(policy *policy) (*config, error) {
+	celRules, err := compileCELRules(policy.CELRules, config.rules())
+	if err != nil {
+		return nil, err
+	}
+	if len(celRules) > 0 {
+		withCELRules := *config
+		withCELRules.celRules = append(append([]*celRule{}, config.celRules...), celRules...)
+		config = &withCELRules
+	}
+
+	settings, err := policy.settings(config)
+	if err != nil {
+		return nil, err
+	}
+	if enforcement, ok := settings["rule-enforcement"].([]string); ok {
+		settings["rule-enforcement"] = append(append([]string{}, config.RuleEnforcement...), enforcement...)
+	}
+	result, err := config.with(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if policy.Profiles != nil {
+		for name, profile := range policy.Profiles {
+			if profile == nil {
+				return nil, fmt.Errorf("Empty profile '%s'", name)
+			}
+			if profile.Profiles != nil || profile.CELRules != nil {
+				return nil, fmt.Errorf("Profile '%s' cannot define profiles or CEL rules", name)
+			}
+			if _, err := result.withPolicy(profile); err != nil {
+				return nil, fmt.Errorf("Profile '%s': %v", name, err)
+			}
+		}
+		result.profiles = policy.Profiles
+	}
+	return result, nil
+}
+
+// Returns a copy of the config with the settings, keyed like the flags, applied.
+func (config *config) with(settings map[string]interface{}) (*config, error) {
+	result := *config
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		// slices of the copy must not overwrite those shared with the original
+		ZeroFields: true,
+		Result:     &result,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(settings); err != nil {
+		return nil, err
+	}
+	if err := result.init(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Returns the config with the --policy-file applied, if set.
+func (config *config) withPolicyFile() (*config, error) {
+	if config.PolicyFile == "" {
+		return config, nil
+	}
+	data, err := ioutil.ReadFile(config.PolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	return config.withPolicyData(data)
+}
+
+func (config *config) withPolicyData(data []byte) (*config, error) {
+	policy, err := parsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid policy file '%s': %v", config.PolicyFile, err)
+	}
+	result, err := config.withPolicy(policy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid policy file '%s': %v", config.PolicyFile, err)
+	}
+	return result, nil
+}
+
+func isConfigKey(key string) bool {
+	return hasConfigKey(reflect.TypeOf(config{}), key)
+}
+
+// Looks for the key among the fields of the struct, including those of
+// structs embedded by ',squash'.
+func hasConfigKey(structType reflect.Type, key string) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == key || (tag == ",squash" && hasConfigKey(field.Type, key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reapplies the policy file on top of the flags whenever its content changes.
+type policyFileWatcher struct {
+	flags   *config
+	configs *configStore
+
+	content []byte
+}
+
+func (watcher *policyFileWatcher) load() error {
+	content, err := ioutil.ReadFile(watcher.flags.PolicyFile)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(content, watcher.content) {
+		return nil
+	}
+
+	config, err := watcher.flags.withPolicyData(content)
+	if err != nil {
+		return err
+	}
+	watcher.configs.set(config)
+	log.Infof("Loaded policy from %s", watcher.flags.PolicyFile)
+
+	watcher.content = content
+	return nil
+}
+
+// Checks the file periodically. An invalid policy keeps the previous one in
+// place until the next successful load.
+func (watcher *policyFileWatcher) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := watcher.load(); err != nil {
+			log.Errorf("Could not reload policy: %v", err)
+		}
+	}
+}
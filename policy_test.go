@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	initLogger()
+
+	t.Run("should apply rules, parameters, enforcement and messages", func(t *testing.T) {
+		flags := &config{RuleIngressCollision: true, RuleEnforcement: []string{"ingress-collision=warn"}}
+		assert.NoError(t, flags.init())
+
+		config, err := flags.withPolicyData([]byte(`
+rules:
+  resource-limit-cpu-required:
+    enforcement: "off"
+  security-readonly-rootfs-required:
+    parameters:
+      whitelist-enabled: true
+  ingress-collision:
+    enabled: false
+messages:
+  resource: "See the wiki"
+`))
+		assert.NoError(t, err)
+		assert.True(t, config.RuleResourceLimitCPURequired)
+		assert.Equal(t, enforcementOff, config.enforcement("resource-limit-cpu-required"))
+		assert.True(t, config.RuleSecurityReadonlyRootFilesystemRequired)
+		assert.True(t, config.RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled)
+		assert.False(t, config.RuleIngressCollision)
+		assert.Equal(t, enforcementWarn, config.enforcement("ingress-collision"))
+		assert.Equal(t, "See the wiki", config.RuleResourceViolationMessage)
+
+		// the flags are left untouched
+		assert.True(t, flags.RuleIngressCollision)
+		assert.Equal(t, []string{"ingress-collision=warn"}, flags.RuleEnforcement)
+		assert.Equal(t, enforcementDeny, flags.enforcement("resource-limit-cpu-required"))
+	})
+
+	t.Run("should accept JSON", func(t *testing.T) {
+		config, err := (&config{}).withPolicyData([]byte(`{"rules": {"resource-request-memory-required": {}}}`))
+		assert.NoError(t, err)
+		assert.True(t, config.RuleResourceRequestMemoryRequired)
+	})
+
+	t.Run("should reject invalid policies", func(t *testing.T) {
+		for _, policy := range []string{
+			"rules:\n  unknown-rule: {}\n",
+			"rules:\n  resource-limit-cpu-required:\n    parameters:\n      unknown: 1\n",
+			"rules:\n  resource-limit-cpu-required:\n    enforcement: maybe\n",
+			"rule:\n  resource-limit-cpu-required: {}\n",
+		} {
+			_, err := (&config{}).withPolicyData([]byte(policy))
+			assert.Error(t, err, policy)
+		}
+	})
+
+	t.Run("should reload changed policy and keep it when invalid", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "policy")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		policyFile := filepath.Join(dir, "policy.yaml")
+
+		configs := &configStore{}
+		watcher := &policyFileWatcher{flags: &config{PolicyFile: policyFile}, configs: configs}
+		assert.NoError(t, ioutil.WriteFile(policyFile, []byte("rules:\n  resource-limit-cpu-required: {}\n"), 0600))
+		assert.NoError(t, watcher.load())
+		assert.True(t, configs.get().RuleResourceLimitCPURequired)
+
+		assert.NoError(t, ioutil.WriteFile(policyFile, []byte("rules:\n  resource-limit-memory-required: {}\n"), 0600))
+		assert.NoError(t, watcher.load())
+		assert.False(t, configs.get().RuleResourceLimitCPURequired)
+		assert.True(t, configs.get().RuleResourceLimitMemoryRequired)
+
+		assert.NoError(t, ioutil.WriteFile(policyFile, []byte("rules: ["), 0600))
+		assert.Error(t, watcher.load())
+		assert.True(t, configs.get().RuleResourceLimitMemoryRequired)
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// A check of admitted or scanned objects. Every rule lives in its own file
+// and adds itself to the registry by registerRule, the webhook, the scanner,
+// the flags and the policy file all work with the registered rules.
+type rule interface {
+	// Identifies the rule, its flag is 'rule-<ID>'.
+	ID() string
+	// Used as the help of the rule's flag.
+	Description() string
+	// Kinds of objects the rule applies to.
+	Kinds() []string
+	// Settings of the rule besides being enabled, each set by the flag
+	// 'rule-<ID>-<name>' or by the rule's parameters in the policy file. Their
+	// values are kept in the config, under the key of the flag.
+	Parameters() []ruleParameter
+	Enabled(config *config) bool
+	Check(object *admittedObject, config *config) ([]validationViolation, error)
+}
+
+// Implemented by rules whose parameters need to be checked beyond their type,
+// called whenever the config is initialized. Parameters which have to be
+// parsed or compiled are kept in unexported fields of the config then, so that
+// checks do not repeat it for every object.
+type validatedRule interface {
+	Validate(config *config) error
+}
+
+type ruleParameter struct {
+	Name string
+	// Default value, its type is also the type of the flag
+	Default     interface{}
+	Description string
+}
+
+// An object to be checked by the rules, decoded just once for all of them.
+type admittedObject struct {
+	Kind    string
+	ObjMeta *metav1.ObjectMeta
+	// Set for kinds carrying a pod specification
+	PodTemplate *podTemplate
+	// Set for ingresses
+	Ingress *extv1beta1.Ingress
+	// The admitted object and the old one when updating, as JSON
+	Raw    []byte
+	OldRaw []byte
+	// The object when scanned, instead of Raw
+	Object    interface{}
+	UserInfo  *authenticationv1.UserInfo
+	ClientSet kubernetes.Interface
+}
+
+var ruleRegistry []rule
+
+// Kinds carrying a pod specification, see decodePodTemplate.
+var podTemplateKinds = []string{"Pod", "ReplicaSet", "Deployment", "DaemonSet", "Job", "CronJob", "StatefulSet"}
+
+// Adds the rule to the registry. Meant to be assigned to a package variable,
+// so that rules are registered before the flags are set up in init().
+func registerRule(r rule) rule {
+	for _, registered := range ruleRegistry {
+		if registered.ID() == r.ID() {
+			panic(fmt.Sprintf("rule '%s' registered twice", r.ID()))
+		}
+	}
+	ruleRegistry = append(ruleRegistry, r)
+	return r
+}
+
+// Returns the registered rules followed by the CEL rules of the policy.
+func (config *config) rules() []rule {
+	rules := append([]rule{}, ruleRegistry...)
+	for _, r := range config.celRules {
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func (config *config) findRule(ruleID string) rule {
+	for _, r := range config.rules() {
+		if r.ID() == ruleID {
+			return r
+		}
+	}
+	return nil
+}
+
+func ruleParameterByName(r rule, name string) *ruleParameter {
+	for _, parameter := range r.Parameters() {
+		if parameter.Name == name {
+			return &parameter
+		}
+	}
+	return nil
+}
+
+func ruleAppliesTo(r rule, kind string) bool {
+	for _, ruleKind := range r.Kinds() {
+		if ruleKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the rule is enabled and not switched off by its enforcement level.
+func (config *config) ruleActive(r rule) bool {
+	return r.Enabled(config) && config.enforcement(r.ID()) != enforcementOff
+}
+
+// Returns the kinds which any of the active rules applies to.
+func (config *config) activeKinds() []string {
+	var kinds []string
+	seen := map[string]bool{}
+	for _, r := range config.rules() {
+		if !config.ruleActive(r) {
+			continue
+		}
+		for _, kind := range r.Kinds() {
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	return kinds
+}
+
+func (config *config) kindActive(kind string) bool {
+	for _, activeKind := range config.activeKinds() {
+		if activeKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the active rules applicable to the object and adds their violations.
+func checkRules(validation *objectValidation, object *admittedObject, config *config) error {
+	for _, r := range config.rules() {
+		if !ruleAppliesTo(r, object.Kind) || !config.ruleActive(r) {
+			continue
+		}
+		violations, err := r.Check(object, config)
+		if err != nil {
+			return err
+		}
+		for _, violation := range violations {
+			validation.Violations.add(violation)
+		}
+	}
+	return nil
+}
+
+// Decodes an object of given kind. Kinds without a pod specification other
+// than ingresses are decoded just for their metadata.
+func decodeAdmittedObject(kind string, raw []byte) (*admittedObject, error) {
+	var object *admittedObject
+	if kind == "Ingress" {
+		ingress := extv1beta1.Ingress{}
+		if _, _, err := codecs.UniversalDeserializer().Decode(raw, nil, &ingress); err != nil {
+			return nil, err
+		}
+		object = ingressObject(&ingress)
+	} else if template, err := decodePodTemplate(kind, raw); err != nil {
+		return nil, err
+	} else if template != nil {
+		object = &admittedObject{Kind: kind, ObjMeta: template.ObjMeta, PodTemplate: template}
+	} else {
+		partial := metav1.PartialObjectMetadata{}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &partial); err != nil {
+				return nil, err
+			}
+		}
+		object = &admittedObject{Kind: kind, ObjMeta: &partial.ObjectMeta}
+	}
+	object.Raw = raw
+	return object, nil
+}
+
+func podObject(pod *corev1.Pod) *admittedObject {
+	return &admittedObject{
+		Kind:        "Pod",
+		ObjMeta:     &pod.ObjectMeta,
+		PodTemplate: &podTemplate{&pod.ObjectMeta, &pod.ObjectMeta, &pod.Spec, "spec"},
+		Object:      pod,
+	}
+}
+
+// For workloads carrying a pod template, e.g. deployments.
+func workloadObject(kind string, objMeta *metav1.ObjectMeta, template *corev1.PodTemplateSpec, specPath string, object interface{}) *admittedObject {
+	return &admittedObject{
+		Kind:        kind,
+		ObjMeta:     objMeta,
+		PodTemplate: &podTemplate{objMeta, &template.ObjectMeta, &template.Spec, specPath},
+		Object:      object,
+	}
+}
+
+func ingressObject(ingress *extv1beta1.Ingress) *admittedObject {
+	return &admittedObject{Kind: "Ingress", ObjMeta: &ingress.ObjectMeta, Ingress: ingress, Object: ingress}
+}
+
+// Returns the additional message configured for violations of the kind.
+func (config *config) violationMessage(kind string) string {
+	if kind == "Ingress" {
+		return config.RuleIngressViolationMessage
+	}
+	for _, podTemplateKind := range podTemplateKinds {
+		if kind == podTemplateKind {
+			return config.RuleResourceViolationMessage
+		}
+	}
+	return ""
+}
+
+// Adds the flags of all registered rules.
+func addRuleFlags(cmd *cobra.Command) {
+	for _, r := range ruleRegistry {
+		cmd.Flags().Bool("rule-"+r.ID(), false, r.Description())
+		for _, parameter := range r.Parameters() {
+			name := fmt.Sprintf("rule-%s-%s", r.ID(), parameter.Name)
+			switch value := parameter.Default.(type) {
+			case bool:
+				cmd.Flags().Bool(name, value, parameter.Description)
+			case string:
+				cmd.Flags().String(name, value, parameter.Description)
+			case int:
+				cmd.Flags().Int(name, value, parameter.Description)
+			case float64:
+				cmd.Flags().Float64(name, value, parameter.Description)
+			case time.Duration:
+				cmd.Flags().Duration(name, value, parameter.Description)
+			case []string:
+				cmd.Flags().StringSlice(name, value, parameter.Description)
+			default:
+				panic(fmt.Sprintf("unsupported type %T of parameter '%s'", value, name))
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	log "github.com/sirupsen/logrus"
+)
+
+var celRuleIDRegExp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// Custom rule of the policy file, e.g.
+//
+//	celRules:
+//	- id: dev-replicas
+//	  kinds: [Deployment, StatefulSet]
+//	  expression: "namespaceObject.name != 'dev' || object.spec.replicas <= 10"
+//	  message: "At most 10 replicas are allowed in dev, not {{.object.spec.replicas}}."
+//
+// The expression must evaluate to true for valid objects. It can use the
+// variables 'object', 'oldObject' (null unless updating), 'userInfo' of the
+// request (empty when scanning) and 'namespaceObject' metadata ('namespace' is
+// reserved in CEL), the message template can use the same ones.
+type policyCELRule struct {
+	ID         string   `json:"id"`
+	Kinds      []string `json:"kinds"`
+	Expression string   `json:"expression"`
+	Message    string   `json:"message"`
+}
+
+type celRule struct {
+	id      string
+	kinds   []string
+	program cel.Program
+	message *template.Template
+}
+
+var celEnv *cel.Env
+
+func init() {
+	var err error
+	celEnv, err = cel.NewEnv(cel.Declarations(
+		decls.NewVar("object", decls.Dyn),
+		decls.NewVar("oldObject", decls.Dyn),
+		decls.NewVar("userInfo", decls.Dyn),
+		decls.NewVar("namespaceObject", decls.Dyn),
+	))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func compileCELRule(definition policyCELRule) (*celRule, error) {
+	if !celRuleIDRegExp.MatchString(definition.ID) {
+		return nil, fmt.Errorf("Invalid CEL rule ID '%s', expected lowercase alphanumerics and dashes", definition.ID)
+	}
+	if len(definition.Kinds) == 0 {
+		return nil, fmt.Errorf("CEL rule '%s' has no kinds", definition.ID)
+	}
+	ast, issues := celEnv.Compile(definition.Expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("CEL rule '%s': %v", definition.ID, issues.Err())
+	}
+	program, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("CEL rule '%s': %v", definition.ID, err)
+	}
+	message := definition.Message
+	if message == "" {
+		message = fmt.Sprintf("Expression '%s' must be true.", strings.TrimSpace(definition.Expression))
+	}
+	messageTemplate, err := template.New(definition.ID).Parse(message)
+	if err != nil {
+		return nil, fmt.Errorf("CEL rule '%s' message: %v", definition.ID, err)
+	}
+	return &celRule{definition.ID, definition.Kinds, program, messageTemplate}, nil
+}
+
+func (r *celRule) ID() string {
+	return r.id
+}
+
+func (r *celRule) Description() string {
+	return fmt.Sprintf("CEL rule '%s'", r.id)
+}
+
+func (r *celRule) Kinds() []string {
+	return r.kinds
+}
+
+func (r *celRule) Parameters() []ruleParameter {
+	return nil
+}
+
+// Always enabled once defined, it can be switched off by its enforcement level.
+func (r *celRule) Enabled(config *config) bool {
+	return true
+}
+
+// Expressions which cannot be evaluated on the object, e.g. accessing a missing
+// field, are reported as violations of the rule.
+func (r *celRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	variables, err := celVariables(object, config)
+	if err != nil {
+		return nil, err
+	}
+	targetDesc := fmt.Sprintf("%s %s", object.Kind, object.ObjMeta.Name)
+	violation := func(format string, args ...interface{}) []validationViolation {
+		return []validationViolation{{TargetDesc: targetDesc, Message: fmt.Sprintf(format, args...), RuleID: r.id}}
+	}
+
+	out, _, err := r.program.Eval(variables)
+	if err != nil {
+		return violation("Expression of CEL rule '%s' could not be evaluated: %v", r.id, err), nil
+	}
+	valid, ok := out.Value().(bool)
+	if !ok {
+		return violation("Expression of CEL rule '%s' evaluated to %v, expected a bool.", r.id, out.Value()), nil
+	}
+	if valid {
+		return nil, nil
+	}
+
+	var message bytes.Buffer
+	if err := r.message.Execute(&message, variables); err != nil {
+		return violation("Message of CEL rule '%s' could not be rendered: %v", r.id, err), nil
+	}
+	return violation("%s", message.String()), nil
+}
+
+func celVariables(object *admittedObject, config *config) (map[string]interface{}, error) {
+	objectValue, err := unstructuredValue(object.Raw, object.Object)
+	if err != nil {
+		return nil, err
+	}
+	oldObjectValue, err := unstructuredValue(object.OldRaw, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := map[string]interface{}{}
+	if object.ObjMeta.Namespace != "" {
+		namespace["name"] = object.ObjMeta.Namespace
+	}
+	if meta, err := config.namespaces.get(object.ObjMeta.Namespace); err != nil {
+		log.Warnf("Could not get namespace '%s', CEL rules get just its name: %v", object.ObjMeta.Namespace, err)
+	} else if meta != nil {
+		namespace = map[string]interface{}{"name": meta.Name, "labels": meta.Labels, "annotations": meta.Annotations}
+	}
+
+	var userInfo interface{} = map[string]interface{}{}
+	if object.UserInfo != nil {
+		if userInfo, err = unstructuredValue(nil, object.UserInfo); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]interface{}{
+		"object":          objectValue,
+		"oldObject":       oldObjectValue,
+		"userInfo":        userInfo,
+		"namespaceObject": namespace,
+	}, nil
+}
+
+// Returns the raw JSON, or the typed value if there is no raw one, as plain
+// maps and slices, or nil if there is neither. Whole numbers become integers,
+// so that CEL can compare them with integer literals.
+func unstructuredValue(raw []byte, typed interface{}) (interface{}, error) {
+	if raw == nil && typed != nil {
+		var err error
+		if raw, err = json.Marshal(typed); err != nil {
+			return nil, err
+		}
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	return wholeNumbersToInt(value), nil
+}
+
+func wholeNumbersToInt(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = wholeNumbersToInt(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = wholeNumbersToInt(item)
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	}
+	return value
+}
+
+// Compiles the CEL rules of a policy, their IDs must not collide with the
+// rules already known.
+func compileCELRules(definitions []policyCELRule, known []rule) ([]*celRule, error) {
+	var compiled []*celRule
+	ids := map[string]bool{}
+	for _, r := range known {
+		ids[r.ID()] = true
+	}
+	for _, definition := range definitions {
+		if ids[definition.ID] {
+			return nil, fmt.Errorf("Rule '%s' is already defined", definition.ID)
+		}
+		ids[definition.ID] = true
+		r, err := compileCELRule(definition)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCELRules(t *testing.T) {
+	initLogger()
+
+	policyConfig := func(policy string) *config {
+		config, err := (&config{}).withPolicyData([]byte(policy))
+		assert.NoError(t, err)
+		return config
+	}
+
+	t.Run("should report violations with templated message", func(t *testing.T) {
+		config := policyConfig(`
+celRules:
+- id: team-label-required
+  kinds: [Pod]
+  expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"
+  message: "Pod {{.object.metadata.name}} must have a team label."
+`)
+		r := config.findRule("team-label-required")
+		assert.NotNil(t, r)
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}
+		violations := checkPod(t, r, pod, config)
+		assert.Equal(t, []validationViolation{{TargetDesc: "Pod pod", Message: "Pod pod must have a team label.", RuleID: "team-label-required"}}, violations)
+
+		pod.Labels = map[string]string{"team": "a-team"}
+		assert.Empty(t, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should use default message", func(t *testing.T) {
+		config := policyConfig("celRules:\n- id: no-pods\n  kinds: [Pod]\n  expression: 'false'\n")
+		violations := checkPod(t, config.findRule("no-pods"), &corev1.Pod{}, config)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, "Expression 'false' must be true.", violations[0].Message)
+	})
+
+	t.Run("should see old object, user info and namespace", func(t *testing.T) {
+		config := policyConfig(`
+celRules:
+- id: replicas-frozen
+  kinds: [Deployment]
+  expression: >
+    oldObject == null || object.spec.replicas == oldObject.spec.replicas ||
+    userInfo.username == 'admin' || namespaceObject.name == 'dev'
+`)
+		ar := admissionReview("Deployment", `{"metadata": {"name": "deployment"}, "spec": {"replicas": 3, "template": {"spec": {}}}}`)
+		ar.Request.Namespace = "prod"
+		ar.Request.UserInfo = authenticationv1.UserInfo{Username: "developer"}
+		assert.True(t, validate(ar, config, nil).Allowed)
+
+		ar.Request.OldObject = runtime.RawExtension{Raw: []byte(`{"metadata": {"name": "deployment"}, "spec": {"replicas": 2}}`)}
+		response := validate(ar, config, nil)
+		assert.False(t, response.Allowed)
+		assert.Contains(t, response.Result.Message, "Deployment deployment: [Expression")
+
+		ar.Request.UserInfo.Username = "admin"
+		assert.True(t, validate(ar, config, nil).Allowed)
+
+		ar.Request.UserInfo.Username = "developer"
+		ar.Request.Namespace = "dev"
+		assert.True(t, validate(ar, config, nil).Allowed)
+	})
+
+	t.Run("should be switched off by policy or enforcement", func(t *testing.T) {
+		definition := "celRules:\n- id: no-pods\n  kinds: [Pod]\n  expression: 'false'\n"
+		config := policyConfig(definition + "rules:\n  no-pods:\n    enabled: false\n")
+		assert.False(t, config.kindActive("Pod"))
+
+		config = policyConfig(definition + "rules:\n  no-pods:\n    enforcement: warn\n")
+		response := validate(admissionReview("Pod", incompletePod), config, nil)
+		assert.True(t, response.Allowed)
+		assert.Equal(t, []string{"Pod pod: Expression 'false' must be true."}, response.Warnings)
+	})
+
+	t.Run("should reject invalid rules", func(t *testing.T) {
+		for _, policy := range []string{
+			"celRules:\n- id: broken\n  kinds: [Pod]\n  expression: 'object.'\n",
+			"celRules:\n- id: no-kinds\n  expression: 'true'\n",
+			"celRules:\n- id: Invalid_ID\n  kinds: [Pod]\n  expression: 'true'\n",
+			"celRules:\n- id: bad-message\n  kinds: [Pod]\n  expression: 'true'\n  message: '{{.object'\n",
+			"celRules:\n- id: twice\n  kinds: [Pod]\n  expression: 'true'\n- id: twice\n  kinds: [Pod]\n  expression: 'true'\n",
+			"celRules:\n- id: ingress-collision\n  kinds: [Ingress]\n  expression: 'true'\n",
+			"celRules:\n- id: with-parameters\n  kinds: [Pod]\n  expression: 'true'\nrules:\n  with-parameters:\n    parameters:\n      limit: 1\n",
+			"profiles:\n  strict:\n    celRules:\n    - id: in-profile\n      kinds: [Pod]\n      expression: 'true'\n",
+		} {
+			_, err := (&config{}).withPolicyData([]byte(policy))
+			assert.Error(t, err, policy)
+		}
+	})
+
+	t.Run("should report non-bool results as violations", func(t *testing.T) {
+		config := policyConfig("celRules:\n- id: not-bool\n  kinds: [Pod]\n  expression: '1'\n")
+		violations := checkPod(t, config.findRule("not-bool"), &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod"}}, config)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, "not-bool", violations[0].RuleID)
+		assert.Contains(t, violations[0].Message, "expected a bool")
+	})
+
+	t.Run("should report evaluation errors by enforcement level", func(t *testing.T) {
+		config := policyConfig(`
+celRules:
+- id: missing-field
+  kinds: [Pod]
+  expression: "object.spec.missing == 'value'"
+rules:
+  missing-field:
+    enforcement: warn
+  resource-limit-cpu-required: {}
+`)
+		response := validate(admissionReview("Pod", incompletePod), config, nil)
+		assert.False(t, response.Allowed, "other rules are still checked")
+		assert.Contains(t, response.Result.Message, "'cpu' resource limit must be specified")
+		assert.Len(t, response.Warnings, 1)
+		assert.Contains(t, response.Warnings[0], "Expression of CEL rule 'missing-field' could not be evaluated: no such key: missing")
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ruleImageReference  = "image-reference"
+	ruleImagePullPolicy = "image-pull-policy"
+)
+
+var sha256Digest = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+type imageReferenceConfig struct {
+	RuleImageReference                         bool     `mapstructure:"rule-image-reference"`
+	RuleImageReferenceDigestRequiredNamespaces []string `mapstructure:"rule-image-reference-digest-required-namespaces"`
+	RuleImagePullPolicy                        bool     `mapstructure:"rule-image-pull-policy"`
+}
+
+// Forbids images tagged 'latest' or untagged, as they change under the same
+// reference, and requires images pinned by digests in the selected
+// namespaces.
+type imageReferenceRule struct{}
+
+// Requires 'imagePullPolicy: Always' for images not pinned by a digest, so
+// that every node runs the image currently behind the tag.
+type imagePullPolicyRule struct{}
+
+var (
+	_ = registerRule(&imageReferenceRule{})
+	_ = registerRule(&imagePullPolicyRule{})
+)
+
+func (r *imageReferenceRule) ID() string {
+	return ruleImageReference
+}
+
+func (r *imageReferenceRule) Description() string {
+	return "Whether container images must be tagged with a version other than 'latest' or pinned by a digest."
+}
+
+func (r *imageReferenceRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *imageReferenceRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"digest-required-namespaces", []string{}, "Namespaces, as names or regular expressions, whose images must be pinned by '@sha256:' digests."},
+	}
+}
+
+func (r *imageReferenceRule) Enabled(config *config) bool {
+	return config.RuleImageReference
+}
+
+func (r *imageReferenceRule) Validate(config *config) error {
+	digestNamespaces, err := compileNamespacePatterns(config.RuleImageReferenceDigestRequiredNamespaces)
+	if err != nil {
+		return fmt.Errorf("Invalid --rule-%s-digest-required-namespaces %v", ruleImageReference, err)
+	}
+	config.digestRequiredNamespaces = digestNamespaces
+	return nil
+}
+
+func (r *imageReferenceRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	digestRequired := matchesNamespace(config.digestRequiredNamespaces, object.ObjMeta.Namespace)
+
+	var violations []validationViolation
+	forEachContainerImage(object.PodTemplate, func(targetDesc string, path string, image string, _ corev1.PullPolicy) {
+		_, tag, digest := parseImageReference(image)
+		var msg string
+		if digestRequired && !sha256Digest.MatchString(digest) {
+			msg = fmt.Sprintf("Image '%s' must be pinned by a '@sha256:' digest in namespace '%s'.", image, object.ObjMeta.Namespace)
+		} else if digest == "" && (tag == "" || tag == "latest") {
+			msg = fmt.Sprintf("Image '%s' must be tagged with a version or pinned by a digest, 'latest' and untagged images are not allowed.", image)
+		} else {
+			return
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    msg,
+			RuleID:     ruleImageReference,
+			Field:      path + ".image",
+		})
+	})
+	return violations, nil
+}
+
+func (r *imagePullPolicyRule) ID() string {
+	return ruleImagePullPolicy
+}
+
+func (r *imagePullPolicyRule) Description() string {
+	return "Whether 'imagePullPolicy: Always' is required for container images not pinned by a digest."
+}
+
+func (r *imagePullPolicyRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *imagePullPolicyRule) Parameters() []ruleParameter {
+	return nil
+}
+
+func (r *imagePullPolicyRule) Enabled(config *config) bool {
+	return config.RuleImagePullPolicy
+}
+
+func (r *imagePullPolicyRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainerImage(object.PodTemplate, func(targetDesc string, path string, image string, pullPolicy corev1.PullPolicy) {
+		_, tag, digest := parseImageReference(image)
+		if digest != "" {
+			return
+		}
+		if pullPolicy == "" {
+			// defaulted the same way by Kubernetes
+			pullPolicy = corev1.PullIfNotPresent
+			if tag == "" || tag == "latest" {
+				pullPolicy = corev1.PullAlways
+			}
+		}
+		if pullPolicy == corev1.PullAlways {
+			return
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    fmt.Sprintf("'imagePullPolicy: Always' must be specified for image '%s' not pinned by a digest, not '%s'.", image, pullPolicy),
+			RuleID:     ruleImagePullPolicy,
+			Field:      path + ".imagePullPolicy",
+		})
+	})
+	return violations, nil
+}
+
+// Splits an image reference into its name, tag and digest, e.g.
+// 'registry.example.com:5000/app:1.0@sha256:...'. The tag and the digest are
+// empty if not present.
+func parseImageReference(image string) (name string, tag string, digest string) {
+	name = image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+	return name, tag, digest
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageReferenceRules(t *testing.T) {
+	initLogger()
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	pod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", Image: "registry.example.com:5000/app:1.0", ImagePullPolicy: corev1.PullIfNotPresent},
+					{Name: "pinned", Image: "registry.example.com:5000/app@" + digest, ImagePullPolicy: corev1.PullIfNotPresent},
+					{Name: "latest", Image: "nginx:latest"},
+					{Name: "untagged", Image: "registry.example.com:5000/nginx"},
+				},
+				EphemeralContainers: []corev1.EphemeralContainer{
+					{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: "busybox:1.32", ImagePullPolicy: corev1.PullAlways}},
+				},
+			},
+		}
+	}
+	mutableMsg := "must be tagged with a version or pinned by a digest, 'latest' and untagged images are not allowed."
+
+	t.Run("should forbid latest and untagged images", func(t *testing.T) {
+		r := (&config{}).findRule(ruleImageReference)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container latest", Message: "Image 'nginx:latest' " + mutableMsg,
+				RuleID: ruleImageReference, Field: "spec.containers[2].image"},
+			{TargetDesc: "Container untagged", Message: "Image 'registry.example.com:5000/nginx' " + mutableMsg,
+				RuleID: ruleImageReference, Field: "spec.containers[3].image"},
+		}, checkPod(t, r, pod("default"), &config{}))
+	})
+
+	t.Run("should require digests in selected namespaces", func(t *testing.T) {
+		r := (&config{}).findRule(ruleImageReference)
+		config := testConfig(t, map[string]interface{}{"rule-image-reference-digest-required-namespaces": "prod-.*"})
+		violations := checkPod(t, r, pod("prod-eu"), config)
+		assert.Len(t, violations, 4)
+		assert.Equal(t, "Image 'registry.example.com:5000/app:1.0' must be pinned by a '@sha256:' digest in namespace 'prod-eu'.", violations[0].Message)
+		assert.Equal(t, "Ephemeral container debug", violations[3].TargetDesc)
+		assert.Len(t, checkPod(t, r, pod("default"), config), 2)
+	})
+
+	t.Run("should require pulling images not pinned by digests", func(t *testing.T) {
+		r := (&config{}).findRule(ruleImagePullPolicy)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'imagePullPolicy: Always' must be specified for image 'registry.example.com:5000/app:1.0' not pinned by a digest, not 'IfNotPresent'.",
+				RuleID: ruleImagePullPolicy, Field: "spec.containers[0].imagePullPolicy"},
+		}, checkPod(t, r, pod("default"), &config{}))
+	})
+
+	t.Run("should parse image references", func(t *testing.T) {
+		for image, expected := range map[string][3]string{
+			"nginx":                            {"nginx", "", ""},
+			"nginx:1.19":                       {"nginx", "1.19", ""},
+			"localhost:5000/app":               {"localhost:5000/app", "", ""},
+			"localhost:5000/app:1.0@" + digest: {"localhost:5000/app", "1.0", digest},
+			"registry.example.com/team/app@" + digest: {"registry.example.com/team/app", "", digest},
+		} {
+			name, tag, digest := parseImageReference(image)
+			assert.Equal(t, expected, [3]string{name, tag, digest}, image)
+		}
+	})
+}
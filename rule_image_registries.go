@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const ruleImageRegistries = "image-registries"
+
+type imageRegistriesConfig struct {
+	RuleImageRegistries                 bool     `mapstructure:"rule-image-registries"`
+	RuleImageRegistriesAllowed          []string `mapstructure:"rule-image-registries-allowed"`
+	RuleImageRegistriesNamespaceAllowed []string `mapstructure:"rule-image-registries-namespace-allowed"`
+}
+
+// Requires images of all containers to come from the allowed registries. The
+// namespace parameter extends the allowed registries, to be set by namespace
+// overrides.
+type imageRegistriesRule struct{}
+
+var _ = registerRule(&imageRegistriesRule{})
+
+func (r *imageRegistriesRule) ID() string {
+	return ruleImageRegistries
+}
+
+func (r *imageRegistriesRule) Description() string {
+	return "Whether container images must come from the registries allowed by the rule's parameters."
+}
+
+func (r *imageRegistriesRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *imageRegistriesRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"allowed", []string{}, "Allowed registry/repository prefixes, or regular expressions starting with '^', e.g. 'registry.example.com/'."},
+		{"namespace-allowed", []string{}, "Registries allowed in addition to 'allowed', meant to be set by namespace overrides."},
+	}
+}
+
+func (r *imageRegistriesRule) Enabled(config *config) bool {
+	return config.RuleImageRegistries
+}
+
+func (r *imageRegistriesRule) Validate(config *config) error {
+	registries, err := compileImageRegistries(append(append([]string{}, config.RuleImageRegistriesAllowed...), config.RuleImageRegistriesNamespaceAllowed...))
+	if err != nil {
+		return err
+	}
+	config.imageRegistries = registries
+	return nil
+}
+
+type imageRegistry struct {
+	setting string
+	prefix  string
+	regexp  *regexp.Regexp
+}
+
+// Compiles registries, each either a prefix or a regular expression starting
+// with '^'.
+func compileImageRegistries(settings []string) ([]imageRegistry, error) {
+	var result []imageRegistry
+	for _, setting := range settings {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		if !strings.HasPrefix(setting, "^") {
+			result = append(result, imageRegistry{setting: setting, prefix: setting})
+			continue
+		}
+		re, err := regexp.Compile(setting)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid registry of rule '%s': '%s': %v", ruleImageRegistries, setting, err)
+		}
+		result = append(result, imageRegistry{setting: setting, regexp: re})
+	}
+	return result, nil
+}
+
+// A prefix not ending with '/' has to end at a boundary of the image
+// reference, so that 'registry.example.com' does not allow
+// 'registry.example.com.evil.io/app'.
+func (registry imageRegistry) allows(image string) bool {
+	if registry.regexp != nil {
+		return registry.regexp.MatchString(image)
+	}
+	if !strings.HasPrefix(image, registry.prefix) {
+		return false
+	}
+	rest := image[len(registry.prefix):]
+	return strings.HasSuffix(registry.prefix, "/") || rest == "" || strings.ContainsAny(rest[:1], "/:@")
+}
+
+func (r *imageRegistriesRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	registries := config.imageRegistries
+	var allowedNames []string
+	for _, registry := range registries {
+		allowedNames = append(allowedNames, registry.setting)
+	}
+
+	var violations []validationViolation
+	forEachContainerImage(object.PodTemplate, func(targetDesc string, path string, image string, _ corev1.PullPolicy) {
+		normalized := normalizeImage(image)
+		for _, registry := range registries {
+			if registry.allows(image) || registry.allows(normalized) {
+				return
+			}
+		}
+		msg := fmt.Sprintf("Image '%s' is not from an allowed registry", image)
+		if len(allowedNames) == 0 {
+			msg += ", no registries are allowed."
+		} else {
+			msg += fmt.Sprintf(", allowed are '%s'.", strings.Join(allowedNames, "', '"))
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    msg,
+			RuleID:     ruleImageRegistries,
+			Field:      path + ".image",
+		})
+	})
+	return violations, nil
+}
+
+// Returns the image reference including the registry and repository implied
+// by Docker, e.g. 'docker.io/library/nginx:1.19' for 'nginx:1.19'.
+func normalizeImage(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
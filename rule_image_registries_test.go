@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageRegistriesRule(t *testing.T) {
+	initLogger()
+
+	r := (&config{}).findRule(ruleImageRegistries)
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "app", Image: "registry.example.com/team/app:1.0"},
+			{Name: "proxy", Image: "nginx:1.19"},
+		},
+		InitContainers: []corev1.Container{
+			{Name: "init", Image: "registry.example.com.evil.io/init"},
+		},
+		EphemeralContainers: []corev1.EphemeralContainer{
+			{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", Image: "quay.io/tools/debug@sha256:abc"}},
+		},
+	}}
+
+	t.Run("should check all containers against allowed registries", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-image-registries-allowed": "registry.example.com"})
+		msg := "is not from an allowed registry, allowed are 'registry.example.com'."
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container proxy", Message: "Image 'nginx:1.19' " + msg,
+				RuleID: ruleImageRegistries, Field: "spec.containers[1].image"},
+			{TargetDesc: "Init container init", Message: "Image 'registry.example.com.evil.io/init' " + msg,
+				RuleID: ruleImageRegistries, Field: "spec.initContainers[0].image"},
+			{TargetDesc: "Ephemeral container debug", Message: "Image 'quay.io/tools/debug@sha256:abc' " + msg,
+				RuleID: ruleImageRegistries, Field: "spec.ephemeralContainers[0].image"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should match regular expressions and implied registries", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-image-registries-allowed": []string{
+			`^registry\.example\.com(\.evil\.io)?/`, "docker.io/library/", "quay.io/tools/",
+		}})
+		assert.Empty(t, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should extend allowed registries by namespace", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-image-registries-allowed": "registry.example.com/"})
+		config, err := config.withNamespaceOverrides(&metav1.ObjectMeta{Annotations: map[string]string{
+			"admission.validation.avast.com/rule-image-registries-namespace-allowed": "quay.io/tools/,nginx",
+		}})
+		assert.NoError(t, err)
+		violations := checkPod(t, r, pod, config)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, "Image 'registry.example.com.evil.io/init' is not from an allowed registry, allowed are 'registry.example.com/', 'quay.io/tools/', 'nginx'.", violations[0].Message)
+	})
+
+	t.Run("should reject invalid regular expressions", func(t *testing.T) {
+		_, err := (&config{}).with(map[string]interface{}{"rule-image-registries-allowed": "^registry.example.com/(team"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+const ruleIngressCollision = "ingress-collision"
+
+// Checks ingress hosts and paths, and their collisions with the other ingresses
+// in the cluster, see ValidateIngress.
+type ingressCollisionRule struct{}
+
+var _ = registerRule(&ingressCollisionRule{})
+
+func (r *ingressCollisionRule) ID() string {
+	return ruleIngressCollision
+}
+
+func (r *ingressCollisionRule) Description() string {
+	return "Whether ingress tls and host collision should be checked"
+}
+
+func (r *ingressCollisionRule) Kinds() []string {
+	return []string{"Ingress"}
+}
+
+func (r *ingressCollisionRule) Parameters() []ruleParameter {
+	return nil
+}
+
+func (r *ingressCollisionRule) Enabled(config *config) bool {
+	return config.RuleIngressCollision
+}
+
+func (r *ingressCollisionRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	validation := &objectValidation{object.Kind, object.ObjMeta, &validationViolationSet{}}
+	if err := ValidateIngress(validation, object.Ingress, config, object.ClientSet); err != nil {
+		return nil, err
+	}
+	return validation.Violations.Violations, nil
+}
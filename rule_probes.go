@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const ruleProbeSettings = "probe-settings"
+
+// Kinds running until stopped, for which probes matter during rollouts,
+// unlike jobs running to completion.
+var longRunningKinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+type probesConfig struct {
+	RuleProbeReadinessRequired              bool `mapstructure:"rule-probe-readiness-required"`
+	RuleProbeLivenessRequired               bool `mapstructure:"rule-probe-liveness-required"`
+	RuleProbeSettings                       bool `mapstructure:"rule-probe-settings"`
+	RuleProbeSettingsMaxInitialDelaySeconds int  `mapstructure:"rule-probe-settings-max-initial-delay-seconds"`
+	RuleProbeSettingsMinTimeoutSeconds      int  `mapstructure:"rule-probe-settings-min-timeout-seconds"`
+	RuleProbeSettingsMaxTimeoutSeconds      int  `mapstructure:"rule-probe-settings-max-timeout-seconds"`
+}
+
+// Requires a probe in every container of long-running workloads. Init
+// containers run to completion and are not probed.
+type probeRequiredRule struct {
+	probe   string
+	enabled func(config *config) bool
+	get     func(container *corev1.Container) *corev1.Probe
+}
+
+// Checks probes which are set, in every container: their ports must be
+// declared by the container and their delays and timeouts must be within the
+// configured bounds, where 0 means unbounded.
+type probeSettingsRule struct{}
+
+var (
+	_ = registerRule(&probeRequiredRule{"readiness",
+		func(config *config) bool { return config.RuleProbeReadinessRequired },
+		func(container *corev1.Container) *corev1.Probe { return container.ReadinessProbe }})
+	_ = registerRule(&probeRequiredRule{"liveness",
+		func(config *config) bool { return config.RuleProbeLivenessRequired },
+		func(container *corev1.Container) *corev1.Probe { return container.LivenessProbe }})
+	_ = registerRule(&probeSettingsRule{})
+)
+
+func (r *probeRequiredRule) ID() string {
+	return "probe-" + r.probe + "-required"
+}
+
+func (r *probeRequiredRule) Description() string {
+	return fmt.Sprintf("Whether '%sProbe' is required in containers of Deployments, StatefulSets and DaemonSets.", r.probe)
+}
+
+func (r *probeRequiredRule) Kinds() []string {
+	return longRunningKinds
+}
+
+func (r *probeRequiredRule) Parameters() []ruleParameter {
+	return nil
+}
+
+func (r *probeRequiredRule) Enabled(config *config) bool {
+	return r.enabled(config)
+}
+
+func (r *probeRequiredRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	podSpec := object.PodTemplate.PodSpec
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if r.get(container) != nil {
+			continue
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: fmt.Sprintf("Container %s", container.Name),
+			Message:    fmt.Sprintf("'%sProbe' must be specified.", r.probe),
+			RuleID:     r.ID(),
+			Field:      fmt.Sprintf("%s.containers[%d].%sProbe", object.PodTemplate.SpecPath, i, r.probe),
+		})
+	}
+	return violations, nil
+}
+
+func (r *probeSettingsRule) ID() string {
+	return ruleProbeSettings
+}
+
+func (r *probeSettingsRule) Description() string {
+	return "Whether probes must reference declared container ports and have delays and timeouts within the bounds set by the rule's parameters."
+}
+
+func (r *probeSettingsRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *probeSettingsRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"max-initial-delay-seconds", 0, "Maximum 'initialDelaySeconds' of probes, 0 for no maximum."},
+		{"min-timeout-seconds", 0, "Minimum 'timeoutSeconds' of probes, 0 for no minimum."},
+		{"max-timeout-seconds", 0, "Maximum 'timeoutSeconds' of probes, 0 for no maximum."},
+	}
+}
+
+func (r *probeSettingsRule) Enabled(config *config) bool {
+	return config.RuleProbeSettings
+}
+
+func (r *probeSettingsRule) Validate(config *config) error {
+	if config.RuleProbeSettingsMaxInitialDelaySeconds < 0 || config.RuleProbeSettingsMinTimeoutSeconds < 0 || config.RuleProbeSettingsMaxTimeoutSeconds < 0 {
+		return fmt.Errorf("Invalid parameter of rule '%s': seconds must not be negative", ruleProbeSettings)
+	}
+	if config.RuleProbeSettingsMaxTimeoutSeconds > 0 && config.RuleProbeSettingsMinTimeoutSeconds > config.RuleProbeSettingsMaxTimeoutSeconds {
+		return fmt.Errorf("Invalid parameter of rule '%s': min-timeout-seconds exceeds max-timeout-seconds", ruleProbeSettings)
+	}
+	return nil
+}
+
+func (r *probeSettingsRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, p := range []struct {
+			name  string
+			probe *corev1.Probe
+		}{
+			{"readinessProbe", container.ReadinessProbe},
+			{"livenessProbe", container.LivenessProbe},
+			{"startupProbe", container.StartupProbe},
+		} {
+			if p.probe == nil {
+				continue
+			}
+			add := func(field string, format string, args ...interface{}) {
+				violations = append(violations, validationViolation{
+					TargetDesc: targetDesc,
+					Message:    fmt.Sprintf("'%s' ", p.name) + fmt.Sprintf(format, args...),
+					RuleID:     ruleProbeSettings,
+					Field:      fmt.Sprintf("%s.%s.%s", path, p.name, field),
+				})
+			}
+
+			if port, field := probePort(p.probe); field != "" && !containerDeclaresPort(container, port) {
+				add(field, "port '%s' must reference a port declared by the container.", port.String())
+			}
+
+			delay := int(p.probe.InitialDelaySeconds)
+			if max := config.RuleProbeSettingsMaxInitialDelaySeconds; max > 0 && delay > max {
+				add("initialDelaySeconds", "initialDelaySeconds %d exceeds the maximum %d.", delay, max)
+			}
+			timeout := int(p.probe.TimeoutSeconds)
+			if timeout == 0 {
+				// defaulted by Kubernetes
+				timeout = 1
+			}
+			if min := config.RuleProbeSettingsMinTimeoutSeconds; min > 0 && timeout < min {
+				add("timeoutSeconds", "timeoutSeconds %d is less than the minimum %d.", timeout, min)
+			}
+			if max := config.RuleProbeSettingsMaxTimeoutSeconds; max > 0 && timeout > max {
+				add("timeoutSeconds", "timeoutSeconds %d exceeds the maximum %d.", timeout, max)
+			}
+		}
+	})
+	return violations, nil
+}
+
+// Returns the port of the probe and its field within the probe, or an empty
+// field for probes without ports.
+func probePort(probe *corev1.Probe) (intstr.IntOrString, string) {
+	if probe.HTTPGet != nil {
+		return probe.HTTPGet.Port, "httpGet.port"
+	}
+	if probe.TCPSocket != nil {
+		return probe.TCPSocket.Port, "tcpSocket.port"
+	}
+	return intstr.IntOrString{}, ""
+}
+
+func containerDeclaresPort(container *corev1.Container, port intstr.IntOrString) bool {
+	for _, declared := range container.Ports {
+		if port.Type == intstr.String && declared.Name == port.StrVal {
+			return true
+		}
+		if port.Type == intstr.Int && declared.ContainerPort == port.IntVal {
+			return true
+		}
+	}
+	return false
+}
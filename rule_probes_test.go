@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestProbeRules(t *testing.T) {
+	initLogger()
+
+	// Handler fields are set by assignment, their embedded struct is named
+	// differently across API versions
+	httpGet := func(port intstr.IntOrString) *corev1.Probe {
+		probe := &corev1.Probe{InitialDelaySeconds: 600}
+		probe.HTTPGet = &corev1.HTTPGetAction{Path: "/health", Port: port}
+		return probe
+	}
+	tcpSocket := func(port intstr.IntOrString, timeout int32) *corev1.Probe {
+		probe := &corev1.Probe{TimeoutSeconds: timeout}
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: port}
+		return probe
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:           "app",
+				Ports:          []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+				ReadinessProbe: httpGet(intstr.FromString("http")),
+				LivenessProbe:  tcpSocket(intstr.FromInt(8081), 0),
+			},
+			{
+				Name:           "sidecar",
+				ReadinessProbe: tcpSocket(intstr.FromString("metrics"), 30),
+			},
+		},
+		InitContainers: []corev1.Container{{Name: "init"}},
+	}}
+
+	t.Run("should require probes in containers of long-running workloads", func(t *testing.T) {
+		readiness := (&config{}).findRule("probe-readiness-required")
+		liveness := (&config{}).findRule("probe-liveness-required")
+		assert.Empty(t, checkPod(t, readiness, pod, &config{}))
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container sidecar", Message: "'livenessProbe' must be specified.",
+				RuleID: "probe-liveness-required", Field: "spec.containers[1].livenessProbe"},
+		}, checkPod(t, liveness, pod, &config{}))
+		assert.Equal(t, []string{"Deployment", "StatefulSet", "DaemonSet"}, liveness.Kinds())
+	})
+
+	t.Run("should check probe ports", func(t *testing.T) {
+		r := (&config{}).findRule(ruleProbeSettings)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'livenessProbe' port '8081' must reference a port declared by the container.",
+				RuleID: ruleProbeSettings, Field: "spec.containers[0].livenessProbe.tcpSocket.port"},
+			{TargetDesc: "Container sidecar", Message: "'readinessProbe' port 'metrics' must reference a port declared by the container.",
+				RuleID: ruleProbeSettings, Field: "spec.containers[1].readinessProbe.tcpSocket.port"},
+		}, checkPod(t, r, pod, &config{}))
+	})
+
+	t.Run("should check probe delays and timeouts", func(t *testing.T) {
+		r := (&config{}).findRule(ruleProbeSettings)
+		config := testConfig(t, map[string]interface{}{
+			"rule-probe-settings-max-initial-delay-seconds": 300,
+			"rule-probe-settings-min-timeout-seconds":       2,
+			"rule-probe-settings-max-timeout-seconds":       10,
+		})
+		var messages []string
+		for _, violation := range checkPod(t, r, pod, config) {
+			messages = append(messages, violation.TargetDesc+": "+violation.Message)
+		}
+		assert.Equal(t, []string{
+			"Container app: 'readinessProbe' initialDelaySeconds 600 exceeds the maximum 300.",
+			"Container app: 'readinessProbe' timeoutSeconds 1 is less than the minimum 2.",
+			"Container app: 'livenessProbe' port '8081' must reference a port declared by the container.",
+			"Container app: 'livenessProbe' timeoutSeconds 1 is less than the minimum 2.",
+			"Container sidecar: 'readinessProbe' port 'metrics' must reference a port declared by the container.",
+			"Container sidecar: 'readinessProbe' timeoutSeconds 30 exceeds the maximum 10.",
+		}, messages)
+	})
+
+	t.Run("should reject invalid bounds", func(t *testing.T) {
+		for _, settings := range []map[string]interface{}{
+			{"rule-probe-settings-max-initial-delay-seconds": -1},
+			{"rule-probe-settings-min-timeout-seconds": 5, "rule-probe-settings-max-timeout-seconds": 2},
+		} {
+			_, err := (&config{}).with(settings)
+			assert.Error(t, err, "%v", settings)
+		}
+	})
+}
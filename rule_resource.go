@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Requires a resource limit or request to be set, or to be nonzero, in every
+// container and init container.
+type resourceRule struct {
+	listName     string
+	resourceName corev1.ResourceName
+	check        string
+	enabled      func(config *config) bool
+}
+
+var (
+	_ = registerRule(&resourceRule{"limit", corev1.ResourceCPU, "required",
+		func(config *config) bool { return config.RuleResourceLimitCPURequired }})
+	_ = registerRule(&resourceRule{"limit", corev1.ResourceCPU, "must-be-nonzero",
+		func(config *config) bool { return config.RuleResourceLimitCPUMustBeNonZero }})
+	_ = registerRule(&resourceRule{"limit", corev1.ResourceMemory, "required",
+		func(config *config) bool { return config.RuleResourceLimitMemoryRequired }})
+	_ = registerRule(&resourceRule{"limit", corev1.ResourceMemory, "must-be-nonzero",
+		func(config *config) bool { return config.RuleResourceLimitMemoryMustBeNonZero }})
+	_ = registerRule(&resourceRule{"request", corev1.ResourceCPU, "required",
+		func(config *config) bool { return config.RuleResourceRequestCPURequired }})
+	_ = registerRule(&resourceRule{"request", corev1.ResourceCPU, "must-be-nonzero",
+		func(config *config) bool { return config.RuleResourceRequestCPUMustBeNonZero }})
+	_ = registerRule(&resourceRule{"request", corev1.ResourceMemory, "required",
+		func(config *config) bool { return config.RuleResourceRequestMemoryRequired }})
+	_ = registerRule(&resourceRule{"request", corev1.ResourceMemory, "must-be-nonzero",
+		func(config *config) bool { return config.RuleResourceRequestMemoryMustBeNonZero }})
+)
+
+func (r *resourceRule) ID() string {
+	return resourceRuleID(r.listName, r.resourceName, r.check)
+}
+
+func (r *resourceRule) Description() string {
+	if r.check == "required" {
+		return fmt.Sprintf("Whether '%s' %s in resource specifications is required.", r.resourceName, r.listName)
+	}
+	return fmt.Sprintf("Whether '%s' %s in resource specifications must be a nonzero value.", r.resourceName, r.listName)
+}
+
+func (r *resourceRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceRule) Parameters() []ruleParameter {
+	return nil
+}
+
+func (r *resourceRule) Enabled(config *config) bool {
+	return r.enabled(config)
+}
+
+func (r *resourceRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	violationSet := &validationViolationSet{}
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		resList := container.Resources.Requests
+		if r.listName == "limit" {
+			resList = container.Resources.Limits
+		}
+		validateResource(violationSet, targetDesc, path, resList, r.listName, r.resourceName, r.check, r.ID())
+	})
+	return violationSet.Violations, nil
+}
+
+// Checks the resource is set, or is nonzero if set, depending on the check.
+func validateResource(violationSet *validationViolationSet, targetDesc string, containerPath string, resList corev1.ResourceList,
+	listName string, name corev1.ResourceName, check string, ruleID string) {
+	field := fmt.Sprintf("%s.resources.%ss.%s", containerPath, listName, name)
+	if check == "required" && !isResourceSet(resList, name) {
+		msg := fmt.Sprintf("'%s' resource %s must be specified.", name, listName)
+		violationSet.add(validationViolation{TargetDesc: targetDesc, Message: msg, RuleID: ruleID, Field: field})
+	}
+	if check == "must-be-nonzero" && !isResourceNonZero(resList, name) {
+		msg := fmt.Sprintf("'%s' resource %s must be a nonzero value.", name, listName)
+		violationSet.add(validationViolation{TargetDesc: targetDesc, Message: msg, RuleID: ruleID, Field: field})
+	}
+}
+
+// Returns e.g. "resource-limit-cpu-required", matching the name of the rule's flag.
+func resourceRuleID(listName string, name corev1.ResourceName, check string) string {
+	return fmt.Sprintf("resource-%s-%s-%s", listName, name, check)
+}
+
+func isResourceSet(resList corev1.ResourceList, name corev1.ResourceName) bool {
+	var missing = resList == nil
+	if !missing {
+		if _, ok := resList[name]; !ok {
+			missing = true
+		}
+	}
+	return !missing
+}
+
+func isResourceNonZero(resList corev1.ResourceList, name corev1.ResourceName) bool {
+	if resList == nil {
+		return true
+	}
+	if r, ok := resList[name]; ok {
+		return !r.IsZero()
+	} else {
+		return true
+	}
+}
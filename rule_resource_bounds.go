@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	ruleResourceBounds             = "resource-bounds"
+	ruleResourceRequestWithinLimit = "resource-request-within-limit"
+	ruleResourceLimitRequestRatio  = "resource-limit-request-ratio"
+)
+
+type resourceBoundsConfig struct {
+	RuleResourceBounds               bool     `mapstructure:"rule-resource-bounds"`
+	RuleResourceBoundsMinRequests    []string `mapstructure:"rule-resource-bounds-min-requests"`
+	RuleResourceBoundsMaxRequests    []string `mapstructure:"rule-resource-bounds-max-requests"`
+	RuleResourceBoundsMinLimits      []string `mapstructure:"rule-resource-bounds-min-limits"`
+	RuleResourceBoundsMaxLimits      []string `mapstructure:"rule-resource-bounds-max-limits"`
+	RuleResourceRequestWithinLimit   bool     `mapstructure:"rule-resource-request-within-limit"`
+	RuleResourceLimitRequestRatio    bool     `mapstructure:"rule-resource-limit-request-ratio"`
+	RuleResourceLimitRequestRatioMax []string `mapstructure:"rule-resource-limit-request-ratio-max"`
+}
+
+// Requires resource requests and limits which are set to be within the
+// configured bounds, in every container and init container. Missing values
+// are left to the 'required' rules.
+type resourceBoundsRule struct{}
+
+// Requires resource requests not to exceed the limits of the same resource.
+type resourceRequestWithinLimitRule struct{}
+
+// Limits how many times a resource limit can exceed its request, to prevent
+// extreme overcommit.
+type resourceLimitRequestRatioRule struct{}
+
+var (
+	_ = registerRule(&resourceBoundsRule{})
+	_ = registerRule(&resourceRequestWithinLimitRule{})
+	_ = registerRule(&resourceLimitRequestRatioRule{})
+)
+
+func (r *resourceBoundsRule) ID() string {
+	return ruleResourceBounds
+}
+
+func (r *resourceBoundsRule) Description() string {
+	return "Whether resource requests and limits must be within the bounds set by the rule's parameters."
+}
+
+func (r *resourceBoundsRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceBoundsRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"min-requests", []string{}, "Minimum resource requests as '<resource>=<quantity>', e.g. 'cpu=50m,memory=32Mi'."},
+		{"max-requests", []string{}, "Maximum resource requests as '<resource>=<quantity>', e.g. 'cpu=4,memory=8Gi'."},
+		{"min-limits", []string{}, "Minimum resource limits as '<resource>=<quantity>', e.g. 'memory=64Mi'."},
+		{"max-limits", []string{}, "Maximum resource limits as '<resource>=<quantity>', e.g. 'cpu=8,memory=16Gi'."},
+	}
+}
+
+func (r *resourceBoundsRule) Enabled(config *config) bool {
+	return config.RuleResourceBounds
+}
+
+func (r *resourceBoundsRule) Validate(config *config) error {
+	bounds, err := config.parseResourceBounds()
+	if err != nil {
+		return err
+	}
+	config.resourceBounds = bounds
+	return nil
+}
+
+type resourceBound struct {
+	listName string
+	max      bool
+	bounds   corev1.ResourceList
+}
+
+func (config *config) parseResourceBounds() ([]resourceBound, error) {
+	var result []resourceBound
+	for _, b := range []struct {
+		parameter string
+		settings  []string
+		listName  string
+		max       bool
+	}{
+		{"min-requests", config.RuleResourceBoundsMinRequests, "request", false},
+		{"max-requests", config.RuleResourceBoundsMaxRequests, "request", true},
+		{"min-limits", config.RuleResourceBoundsMinLimits, "limit", false},
+		{"max-limits", config.RuleResourceBoundsMaxLimits, "limit", true},
+	} {
+		bounds, err := parseResourceQuantities(b.settings)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --rule-%s-%s: %v", ruleResourceBounds, b.parameter, err)
+		}
+		result = append(result, resourceBound{b.listName, b.max, bounds})
+	}
+	return result, nil
+}
+
+func (r *resourceBoundsRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	bounds := config.resourceBounds
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, b := range bounds {
+			resList := container.Resources.Requests
+			if b.listName == "limit" {
+				resList = container.Resources.Limits
+			}
+			for _, name := range sortedResourceNames(b.bounds) {
+				value, ok := resList[name]
+				if !ok {
+					continue
+				}
+				bound := b.bounds[name]
+				var msg string
+				if b.max && value.Cmp(bound) > 0 {
+					msg = fmt.Sprintf("'%s' resource %s %s exceeds the maximum %s.", name, b.listName, value.String(), bound.String())
+				} else if !b.max && value.Cmp(bound) < 0 {
+					msg = fmt.Sprintf("'%s' resource %s %s is less than the minimum %s.", name, b.listName, value.String(), bound.String())
+				} else {
+					continue
+				}
+				violations = append(violations, validationViolation{
+					TargetDesc: targetDesc,
+					Message:    msg,
+					RuleID:     ruleResourceBounds,
+					Field:      fmt.Sprintf("%s.resources.%ss.%s", path, b.listName, name),
+				})
+			}
+		}
+	})
+	return violations, nil
+}
+
+func (r *resourceRequestWithinLimitRule) ID() string {
+	return ruleResourceRequestWithinLimit
+}
+
+func (r *resourceRequestWithinLimitRule) Description() string {
+	return "Whether resource requests must not exceed the limits of the same resources."
+}
+
+func (r *resourceRequestWithinLimitRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceRequestWithinLimitRule) Parameters() []ruleParameter {
+	return nil
+}
+
+func (r *resourceRequestWithinLimitRule) Enabled(config *config) bool {
+	return config.RuleResourceRequestWithinLimit
+}
+
+func (r *resourceRequestWithinLimitRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, name := range sortedResourceNames(container.Resources.Requests) {
+			request := container.Resources.Requests[name]
+			limit, ok := container.Resources.Limits[name]
+			if !ok || request.Cmp(limit) <= 0 {
+				continue
+			}
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    fmt.Sprintf("'%s' resource request %s exceeds its limit %s.", name, request.String(), limit.String()),
+				RuleID:     ruleResourceRequestWithinLimit,
+				Field:      fmt.Sprintf("%s.resources.requests.%s", path, name),
+			})
+		}
+	})
+	return violations, nil
+}
+
+func (r *resourceLimitRequestRatioRule) ID() string {
+	return ruleResourceLimitRequestRatio
+}
+
+func (r *resourceLimitRequestRatioRule) Description() string {
+	return "Whether resource limits can exceed their requests at most the number of times set by the rule's parameter."
+}
+
+func (r *resourceLimitRequestRatioRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceLimitRequestRatioRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"max", []string{}, "Maximum ratio of resource limits to requests as '<resource>=<ratio>', e.g. 'cpu=4,memory=2'."},
+	}
+}
+
+func (r *resourceLimitRequestRatioRule) Enabled(config *config) bool {
+	return config.RuleResourceLimitRequestRatio
+}
+
+func (r *resourceLimitRequestRatioRule) Validate(config *config) error {
+	ratios, err := config.parseResourceLimitRequestRatios()
+	if err != nil {
+		return err
+	}
+	config.resourceLimitRequestRatios = ratios
+	return nil
+}
+
+func (config *config) parseResourceLimitRequestRatios() (map[corev1.ResourceName]float64, error) {
+	ratios := map[corev1.ResourceName]float64{}
+	for _, setting := range config.RuleResourceLimitRequestRatioMax {
+		name, value, err := splitResourceSetting(setting)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --rule-%s-max: %v", ruleResourceLimitRequestRatio, err)
+		}
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 1 {
+			return nil, fmt.Errorf("Invalid --rule-%s-max: ratio '%s' of '%s' must be a number of at least 1", ruleResourceLimitRequestRatio, value, name)
+		}
+		ratios[name] = ratio
+	}
+	return ratios, nil
+}
+
+func (r *resourceLimitRequestRatioRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	ratios := config.resourceLimitRequestRatios
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, name := range sortedResourceNames(container.Resources.Limits) {
+			maxRatio, ok := ratios[name]
+			if !ok {
+				continue
+			}
+			limit := container.Resources.Limits[name]
+			request, ok := container.Resources.Requests[name]
+			if !ok || request.IsZero() {
+				// left to the 'required' and 'nonzero' rules
+				continue
+			}
+			ratio := float64(limit.MilliValue()) / float64(request.MilliValue())
+			if ratio <= maxRatio {
+				continue
+			}
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message: fmt.Sprintf("'%s' resource limit %s is %s times its request %s, at most %s times is allowed.",
+					name, limit.String(), formatRatio(ratio), request.String(), formatRatio(maxRatio)),
+				RuleID: ruleResourceLimitRequestRatio,
+				Field:  fmt.Sprintf("%s.resources.limits.%s", path, name),
+			})
+		}
+	})
+	return violations, nil
+}
+
+func formatRatio(ratio float64) string {
+	return strconv.FormatFloat(math.Round(ratio*100)/100, 'f', -1, 64)
+}
+
+// Parses '<resource>=<quantity>' settings, e.g. 'cpu=500m'.
+func parseResourceQuantities(settings []string) (corev1.ResourceList, error) {
+	quantities := corev1.ResourceList{}
+	for _, setting := range settings {
+		name, value, err := splitResourceSetting(setting)
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity '%s' of '%s': %v", value, name, err)
+		}
+		quantities[name] = quantity
+	}
+	return quantities, nil
+}
+
+func splitResourceSetting(setting string) (corev1.ResourceName, string, error) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return "", "", fmt.Errorf("'%s' is not '<resource>=<value>'", setting)
+	}
+	return corev1.ResourceName(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), nil
+}
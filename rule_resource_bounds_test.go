@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestResourceBoundsRules(t *testing.T) {
+	initLogger()
+
+	resources := func(limits string, requests string) corev1.ResourceRequirements {
+		parse := func(settings string) corev1.ResourceList {
+			if settings == "" {
+				return nil
+			}
+			list, err := parseResourceQuantities(strings.Split(settings, ","))
+			assert.NoError(t, err)
+			return list
+		}
+		return corev1.ResourceRequirements{Limits: parse(limits), Requests: parse(requests)}
+	}
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "app", Resources: resources("cpu=4,memory=1Gi", "cpu=500m,memory=2Gi")},
+			{Name: "unbounded"},
+		},
+		InitContainers: []corev1.Container{
+			{Name: "init", Resources: resources("cpu=100m", "cpu=10m")},
+		},
+	}}
+
+	t.Run("should check minimum and maximum of set values", func(t *testing.T) {
+		r := (&config{}).findRule(ruleResourceBounds)
+		config := testConfig(t, map[string]interface{}{
+			"rule-resource-bounds-min-requests": "cpu=50m",
+			"rule-resource-bounds-max-requests": "memory=1Gi",
+			"rule-resource-bounds-max-limits":   "cpu=2,memory=4Gi",
+		})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'memory' resource request 2Gi exceeds the maximum 1Gi.",
+				RuleID: ruleResourceBounds, Field: "spec.containers[0].resources.requests.memory"},
+			{TargetDesc: "Container app", Message: "'cpu' resource limit 4 exceeds the maximum 2.",
+				RuleID: ruleResourceBounds, Field: "spec.containers[0].resources.limits.cpu"},
+			{TargetDesc: "Init container init", Message: "'cpu' resource request 10m is less than the minimum 50m.",
+				RuleID: ruleResourceBounds, Field: "spec.initContainers[0].resources.requests.cpu"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should require requests within limits", func(t *testing.T) {
+		r := (&config{}).findRule(ruleResourceRequestWithinLimit)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'memory' resource request 2Gi exceeds its limit 1Gi.",
+				RuleID: ruleResourceRequestWithinLimit, Field: "spec.containers[0].resources.requests.memory"},
+		}, checkPod(t, r, pod, &config{}))
+	})
+
+	t.Run("should check limit to request ratio", func(t *testing.T) {
+		r := (&config{}).findRule(ruleResourceLimitRequestRatio)
+		config := testConfig(t, map[string]interface{}{"rule-resource-limit-request-ratio-max": "cpu=5,memory=2"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'cpu' resource limit 4 is 8 times its request 500m, at most 5 times is allowed.",
+				RuleID: ruleResourceLimitRequestRatio, Field: "spec.containers[0].resources.limits.cpu"},
+			{TargetDesc: "Init container init", Message: "'cpu' resource limit 100m is 10 times its request 10m, at most 5 times is allowed.",
+				RuleID: ruleResourceLimitRequestRatio, Field: "spec.initContainers[0].resources.limits.cpu"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should compile parameters with the config", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-resource-bounds-max-limits":       "cpu=2",
+			"rule-resource-limit-request-ratio-max": "cpu=4",
+		})
+		maxLimits := config.resourceBounds[3]
+		assert.Equal(t, "limit", maxLimits.listName)
+		assert.True(t, maxLimits.max)
+		assert.Equal(t, "2", maxLimits.bounds.Cpu().String())
+		assert.Equal(t, map[corev1.ResourceName]float64{corev1.ResourceCPU: 4}, config.resourceLimitRequestRatios)
+
+		derived, err := config.with(map[string]interface{}{
+			"rule-resource-bounds-max-limits":       "cpu=8",
+			"rule-resource-limit-request-ratio-max": "cpu=2",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "8", derived.resourceBounds[3].bounds.Cpu().String())
+		assert.Equal(t, map[corev1.ResourceName]float64{corev1.ResourceCPU: 2}, derived.resourceLimitRequestRatios)
+		assert.Equal(t, "2", config.resourceBounds[3].bounds.Cpu().String())
+		assert.Equal(t, map[corev1.ResourceName]float64{corev1.ResourceCPU: 4}, config.resourceLimitRequestRatios)
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		for _, settings := range []map[string]interface{}{
+			{"rule-resource-bounds-max-limits": "cpu"},
+			{"rule-resource-bounds-min-requests": "memory=lots"},
+			{"rule-resource-limit-request-ratio-max": "cpu=x"},
+			{"rule-resource-limit-request-ratio-max": "cpu=0.5"},
+		} {
+			_, err := (&config{}).with(settings)
+			assert.Error(t, err, "%v", settings)
+		}
+	})
+
+	t.Run("should be configurable by policy", func(t *testing.T) {
+		config, err := (&config{}).withPolicyData([]byte(`
+rules:
+  resource-bounds:
+    parameters:
+      max-limits: [cpu=2]
+  resource-limit-request-ratio:
+    parameters:
+      max: [cpu=4]
+`))
+		assert.NoError(t, err)
+		assert.True(t, config.RuleResourceBounds)
+		assert.Equal(t, []string{"cpu=2"}, config.RuleResourceBoundsMaxLimits)
+		assert.True(t, config.RuleResourceLimitRequestRatio)
+		assert.Equal(t, []string{"cpu=4"}, config.RuleResourceLimitRequestRatioMax)
+	})
+}
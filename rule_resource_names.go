@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const ruleResourceLimitEqualsRequest = "resource-limit-equals-request"
+
+type resourceNamesConfig struct {
+	RuleResourceRequired                    bool     `mapstructure:"rule-resource-required"`
+	RuleResourceRequiredLimits              []string `mapstructure:"rule-resource-required-limits"`
+	RuleResourceRequiredRequests            []string `mapstructure:"rule-resource-required-requests"`
+	RuleResourceMustBeNonZero               bool     `mapstructure:"rule-resource-must-be-nonzero"`
+	RuleResourceMustBeNonZeroLimits         []string `mapstructure:"rule-resource-must-be-nonzero-limits"`
+	RuleResourceMustBeNonZeroRequests       []string `mapstructure:"rule-resource-must-be-nonzero-requests"`
+	RuleResourceLimitEqualsRequest          bool     `mapstructure:"rule-resource-limit-equals-request"`
+	RuleResourceLimitEqualsRequestResources []string `mapstructure:"rule-resource-limit-equals-request-resources"`
+}
+
+// Same as resourceRule, for any resources named by the rule's parameters,
+// e.g. 'ephemeral-storage' or extended resources like 'nvidia.com/gpu'.
+type resourceNamesRule struct {
+	check    string
+	enabled  func(config *config) bool
+	limits   func(config *config) []string
+	requests func(config *config) []string
+	// the config's field keeping the parsed parameters
+	names func(config *config) *resourceNames
+}
+
+type resourceNames struct {
+	limits   []corev1.ResourceName
+	requests []corev1.ResourceName
+}
+
+// Requires limits of resources which cannot be overcommitted, like GPUs, to
+// equal their requests.
+type resourceLimitEqualsRequestRule struct{}
+
+var (
+	_ = registerRule(&resourceNamesRule{"required",
+		func(config *config) bool { return config.RuleResourceRequired },
+		func(config *config) []string { return config.RuleResourceRequiredLimits },
+		func(config *config) []string { return config.RuleResourceRequiredRequests },
+		func(config *config) *resourceNames { return &config.resourceRequiredNames }})
+	_ = registerRule(&resourceNamesRule{"must-be-nonzero",
+		func(config *config) bool { return config.RuleResourceMustBeNonZero },
+		func(config *config) []string { return config.RuleResourceMustBeNonZeroLimits },
+		func(config *config) []string { return config.RuleResourceMustBeNonZeroRequests },
+		func(config *config) *resourceNames { return &config.resourceNonZeroNames }})
+	_ = registerRule(&resourceLimitEqualsRequestRule{})
+)
+
+func (r *resourceNamesRule) ID() string {
+	return "resource-" + r.check
+}
+
+func (r *resourceNamesRule) Description() string {
+	if r.check == "required" {
+		return "Whether limits and requests of the resources named by the rule's parameters are required."
+	}
+	return "Whether limits and requests of the resources named by the rule's parameters must be nonzero values."
+}
+
+func (r *resourceNamesRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceNamesRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"limits", []string{}, "Names of the resources whose limits are checked, e.g. 'ephemeral-storage,nvidia.com/gpu'."},
+		{"requests", []string{}, "Names of the resources whose requests are checked, e.g. 'ephemeral-storage'."},
+	}
+}
+
+func (r *resourceNamesRule) Enabled(config *config) bool {
+	return r.enabled(config)
+}
+
+func (r *resourceNamesRule) Validate(config *config) error {
+	limits, err := parseResourceNames(r.limits(config))
+	if err != nil {
+		return fmt.Errorf("Invalid parameter of rule '%s': %v", r.ID(), err)
+	}
+	requests, err := parseResourceNames(r.requests(config))
+	if err != nil {
+		return fmt.Errorf("Invalid parameter of rule '%s': %v", r.ID(), err)
+	}
+	*r.names(config) = resourceNames{limits, requests}
+	return nil
+}
+
+func (r *resourceNamesRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	names := r.names(config)
+	violationSet := &validationViolationSet{}
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, name := range names.limits {
+			validateResource(violationSet, targetDesc, path, container.Resources.Limits, "limit", name, r.check, r.ID())
+		}
+		for _, name := range names.requests {
+			validateResource(violationSet, targetDesc, path, container.Resources.Requests, "request", name, r.check, r.ID())
+		}
+	})
+	return violationSet.Violations, nil
+}
+
+func (r *resourceLimitEqualsRequestRule) ID() string {
+	return ruleResourceLimitEqualsRequest
+}
+
+func (r *resourceLimitEqualsRequestRule) Description() string {
+	return "Whether limits of the resources named by the rule's parameter must equal their requests."
+}
+
+func (r *resourceLimitEqualsRequestRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *resourceLimitEqualsRequestRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"resources", []string{}, "Names of the resources whose limits must equal requests, e.g. 'nvidia.com/gpu'."},
+	}
+}
+
+func (r *resourceLimitEqualsRequestRule) Enabled(config *config) bool {
+	return config.RuleResourceLimitEqualsRequest
+}
+
+func (r *resourceLimitEqualsRequestRule) Validate(config *config) error {
+	names, err := parseResourceNames(config.RuleResourceLimitEqualsRequestResources)
+	if err != nil {
+		return fmt.Errorf("Invalid parameter of rule '%s': %v", r.ID(), err)
+	}
+	config.resourceLimitEqualsRequestNames = names
+	return nil
+}
+
+// A missing request is fine, Kubernetes defaults it to the limit.
+func (r *resourceLimitEqualsRequestRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for _, name := range config.resourceLimitEqualsRequestNames {
+			limit, hasLimit := container.Resources.Limits[name]
+			request, hasRequest := container.Resources.Requests[name]
+			var msg string
+			if hasRequest && !hasLimit {
+				msg = fmt.Sprintf("'%s' resource limit must be specified, equal to its request %s.", name, request.String())
+			} else if hasRequest && limit.Cmp(request) != 0 {
+				msg = fmt.Sprintf("'%s' resource limit %s must equal its request %s.", name, limit.String(), request.String())
+			} else {
+				continue
+			}
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    msg,
+				RuleID:     ruleResourceLimitEqualsRequest,
+				Field:      fmt.Sprintf("%s.resources.limits.%s", path, name),
+			})
+		}
+	})
+	return violations, nil
+}
+
+func parseResourceNames(names []string) ([]corev1.ResourceName, error) {
+	var result []corev1.ResourceName
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" || strings.Contains(name, "=") {
+			return nil, fmt.Errorf("'%s' is not a resource name", name)
+		}
+		result = append(result, corev1.ResourceName(strings.TrimSpace(name)))
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResourceNamesRules(t *testing.T) {
+	initLogger()
+
+	gpu := corev1.ResourceName("nvidia.com/gpu")
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "app", Resources: corev1.ResourceRequirements{
+				Limits:   corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("0"), gpu: resource.MustParse("2")},
+				Requests: corev1.ResourceList{gpu: resource.MustParse("1")},
+			}},
+			{Name: "trainer", Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"), gpu: resource.MustParse("1")},
+			}},
+		},
+		InitContainers: []corev1.Container{{Name: "init", Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{gpu: resource.MustParse("1")},
+		}}},
+	}}
+
+	t.Run("should require any named resource", func(t *testing.T) {
+		r := (&config{}).findRule("resource-required")
+		config := testConfig(t, map[string]interface{}{"rule-resource-required-limits": "ephemeral-storage"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Init container init", Message: "'ephemeral-storage' resource limit must be specified.",
+				RuleID: "resource-required", Field: "spec.initContainers[0].resources.limits.ephemeral-storage"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should require any named resource to be nonzero", func(t *testing.T) {
+		r := (&config{}).findRule("resource-must-be-nonzero")
+		config := testConfig(t, map[string]interface{}{"rule-resource-must-be-nonzero-limits": "ephemeral-storage"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'ephemeral-storage' resource limit must be a nonzero value.",
+				RuleID: "resource-must-be-nonzero", Field: "spec.containers[0].resources.limits.ephemeral-storage"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should require limits equal to requests", func(t *testing.T) {
+		r := (&config{}).findRule(ruleResourceLimitEqualsRequest)
+		config := testConfig(t, map[string]interface{}{"rule-resource-limit-equals-request-resources": "nvidia.com/gpu"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'nvidia.com/gpu' resource limit 2 must equal its request 1.",
+				RuleID: ruleResourceLimitEqualsRequest, Field: "spec.containers[0].resources.limits.nvidia.com/gpu"},
+			{TargetDesc: "Init container init", Message: "'nvidia.com/gpu' resource limit must be specified, equal to its request 1.",
+				RuleID: ruleResourceLimitEqualsRequest, Field: "spec.initContainers[0].resources.limits.nvidia.com/gpu"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should bound any named resource", func(t *testing.T) {
+		r := (&config{}).findRule(ruleResourceBounds)
+		config := testConfig(t, map[string]interface{}{"rule-resource-bounds-max-limits": "ephemeral-storage=512Mi"})
+		violations := checkPod(t, r, pod, config)
+		assert.Len(t, violations, 1)
+		assert.Equal(t, "'ephemeral-storage' resource limit 1Gi exceeds the maximum 512Mi.", violations[0].Message)
+	})
+
+	t.Run("should parse resource names with the config", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-resource-required-limits":                "ephemeral-storage, nvidia.com/gpu",
+			"rule-resource-limit-equals-request-resources": "nvidia.com/gpu",
+		})
+		assert.Equal(t, []corev1.ResourceName{"ephemeral-storage", "nvidia.com/gpu"}, config.resourceRequiredNames.limits)
+		assert.Equal(t, []corev1.ResourceName{"nvidia.com/gpu"}, config.resourceLimitEqualsRequestNames)
+
+		derived, err := config.with(map[string]interface{}{"rule-resource-required-limits": "cpu"})
+		assert.NoError(t, err)
+		assert.Equal(t, []corev1.ResourceName{"cpu"}, derived.resourceRequiredNames.limits)
+		assert.Equal(t, []corev1.ResourceName{"ephemeral-storage", "nvidia.com/gpu"}, config.resourceRequiredNames.limits)
+	})
+
+	t.Run("should reject invalid resource names", func(t *testing.T) {
+		_, err := (&config{}).with(map[string]interface{}{"rule-resource-required-limits": "cpu=1"})
+		assert.Error(t, err)
+		_, err = (&config{}).with(map[string]interface{}{"rule-resource-limit-equals-request-resources": []string{" "}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResourceRule(t *testing.T) {
+	initLogger()
+
+	cpuLimitRequired := (&config{}).findRule("resource-limit-cpu-required")
+	memoryRequestNonZero := (&config{}).findRule("resource-request-memory-must-be-nonzero")
+
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{{Name: "app", Resources: corev1.ResourceRequirements{
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("0")},
+		}}},
+		InitContainers: []corev1.Container{{Name: "init"}},
+	}}
+
+	t.Run("should check containers and init containers", func(t *testing.T) {
+		violations := checkPod(t, cpuLimitRequired, pod, &config{})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Init container init", Message: "'cpu' resource limit must be specified.", RuleID: "resource-limit-cpu-required", Field: "spec.initContainers[0].resources.limits.cpu"},
+		}, violations)
+	})
+
+	t.Run("should require nonzero values", func(t *testing.T) {
+		violations := checkPod(t, memoryRequestNonZero, pod, &config{})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'memory' resource request must be a nonzero value.", RuleID: "resource-request-memory-must-be-nonzero", Field: "spec.containers[0].resources.requests.memory"},
+		}, violations)
+	})
+
+	t.Run("should be enabled by its flag", func(t *testing.T) {
+		assert.False(t, cpuLimitRequired.Enabled(&config{}))
+		assert.True(t, cpuLimitRequired.Enabled(&config{RuleResourceLimitCPURequired: true}))
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const ruleSecurityCapabilities = "security-capabilities"
+
+// A capability name without the 'CAP_' prefix, e.g. 'NET_BIND_SERVICE'.
+var capabilityName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+type capabilitiesConfig struct {
+	RuleSecurityCapabilities                    bool     `mapstructure:"rule-security-capabilities"`
+	RuleSecurityCapabilitiesAllowedAdd          []string `mapstructure:"rule-security-capabilities-allowed-add"`
+	RuleSecurityCapabilitiesNamespaceAllowedAdd []string `mapstructure:"rule-security-capabilities-namespace-allowed-add"`
+}
+
+// Requires every container, init container and ephemeral container to drop
+// all capabilities and to add back only the allowed ones. The namespace
+// parameter extends the allowed capabilities, to be set by namespace overrides.
+// Without --namespace-overrides it is just a second global list.
+type capabilitiesRule struct{}
+
+var _ = registerRule(&capabilitiesRule{})
+
+func (r *capabilitiesRule) ID() string {
+	return ruleSecurityCapabilities
+}
+
+func (r *capabilitiesRule) Description() string {
+	return "Whether capabilities must drop 'ALL' and add only the capabilities allowed by the rule's parameters."
+}
+
+func (r *capabilitiesRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *capabilitiesRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"allowed-add", []string{}, "Capabilities allowed to be added, e.g. 'NET_BIND_SERVICE'."},
+		{"namespace-allowed-add", []string{}, "Capabilities allowed to be added in addition to 'allowed-add', meant to be set per namespace by annotations. Takes effect per namespace only with --namespace-overrides, otherwise it just extends 'allowed-add' globally."},
+	}
+}
+
+func (r *capabilitiesRule) Enabled(config *config) bool {
+	return config.RuleSecurityCapabilities
+}
+
+func (r *capabilitiesRule) Validate(config *config) error {
+	allowed := map[string]bool{}
+	var allowedNames []string
+	for _, parameter := range []struct {
+		name         string
+		capabilities []string
+	}{
+		{"allowed-add", config.RuleSecurityCapabilitiesAllowedAdd},
+		{"namespace-allowed-add", config.RuleSecurityCapabilitiesNamespaceAllowedAdd},
+	} {
+		for _, capability := range parameter.capabilities {
+			name := normalizeCapability(corev1.Capability(capability))
+			if !capabilityName.MatchString(name) {
+				return fmt.Errorf("Invalid --rule-%s-%s: '%s' is not a capability name", ruleSecurityCapabilities, parameter.name, capability)
+			}
+			if !allowed[name] {
+				allowed[name] = true
+				allowedNames = append(allowedNames, name)
+			}
+		}
+	}
+	config.capabilitiesAllowed, config.capabilitiesAllowedNames = allowed, allowedNames
+	return nil
+}
+
+func (r *capabilitiesRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	allowed, allowedNames := config.capabilitiesAllowed, config.capabilitiesAllowedNames
+
+	var violations []validationViolation
+	forEachContainerSecurityContext(object.PodTemplate, func(targetDesc string, path string, securityContext *corev1.SecurityContext) {
+		var capabilities *corev1.Capabilities
+		if securityContext != nil {
+			capabilities = securityContext.Capabilities
+		}
+
+		if capabilities == nil || !dropsAllCapabilities(capabilities) {
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    "'securityContext' with 'capabilities' dropping 'ALL' must be specified.",
+				RuleID:     ruleSecurityCapabilities,
+				Field:      path + ".securityContext.capabilities.drop",
+			})
+		}
+		if capabilities == nil {
+			return
+		}
+
+		var disallowed []string
+		for _, capability := range capabilities.Add {
+			if name := normalizeCapability(capability); !allowed[name] {
+				disallowed = append(disallowed, name)
+			}
+		}
+		if len(disallowed) == 0 {
+			return
+		}
+		msg := fmt.Sprintf("Adding capabilities '%s' is not allowed", strings.Join(disallowed, ", "))
+		if len(allowedNames) == 0 {
+			msg += ", no capabilities can be added."
+		} else {
+			msg += fmt.Sprintf(", allowed are '%s'.", strings.Join(allowedNames, ", "))
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    msg,
+			RuleID:     ruleSecurityCapabilities,
+			Field:      path + ".securityContext.capabilities.add",
+		})
+	})
+	return violations, nil
+}
+
+func dropsAllCapabilities(capabilities *corev1.Capabilities) bool {
+	for _, capability := range capabilities.Drop {
+		if normalizeCapability(capability) == "ALL" {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities are accepted by container runtimes regardless of case and of
+// the 'CAP_' prefix.
+func normalizeCapability(capability corev1.Capability) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(string(capability))), "CAP_")
+}
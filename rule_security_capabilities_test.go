@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCapabilitiesRule(t *testing.T) {
+	initLogger()
+
+	r := (&config{}).findRule(ruleSecurityCapabilities)
+	withCapabilities := func(drop []corev1.Capability, add ...corev1.Capability) *corev1.SecurityContext {
+		return &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Drop: drop, Add: add}}
+	}
+	all := []corev1.Capability{"all"}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "app", SecurityContext: withCapabilities(all, "NET_BIND_SERVICE")},
+			{Name: "proxy", SecurityContext: withCapabilities(all, "CAP_NET_ADMIN", "net_raw")},
+			{Name: "unrestricted"},
+		},
+		InitContainers: []corev1.Container{
+			{Name: "init", SecurityContext: withCapabilities([]corev1.Capability{"NET_RAW"})},
+		},
+	}}
+
+	t.Run("should require dropping all and adding only allowed capabilities", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-security-capabilities-allowed-add": "NET_BIND_SERVICE"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container proxy", Message: "Adding capabilities 'NET_ADMIN, NET_RAW' is not allowed, allowed are 'NET_BIND_SERVICE'.",
+				RuleID: ruleSecurityCapabilities, Field: "spec.containers[1].securityContext.capabilities.add"},
+			{TargetDesc: "Container unrestricted", Message: "'securityContext' with 'capabilities' dropping 'ALL' must be specified.",
+				RuleID: ruleSecurityCapabilities, Field: "spec.containers[2].securityContext.capabilities.drop"},
+			{TargetDesc: "Init container init", Message: "'securityContext' with 'capabilities' dropping 'ALL' must be specified.",
+				RuleID: ruleSecurityCapabilities, Field: "spec.initContainers[0].securityContext.capabilities.drop"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should allow no capabilities by default", func(t *testing.T) {
+		violations := checkPod(t, r, pod, &config{})
+		assert.Len(t, violations, 4)
+		assert.Equal(t, "Adding capabilities 'NET_BIND_SERVICE' is not allowed, no capabilities can be added.", violations[0].Message)
+	})
+
+	t.Run("should check ephemeral containers", func(t *testing.T) {
+		debugged := &corev1.Pod{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", SecurityContext: withCapabilities(all)}},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug", SecurityContext: withCapabilities(all, "SYS_PTRACE")}},
+			},
+		}}
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Ephemeral container debug", Message: "Adding capabilities 'SYS_PTRACE' is not allowed, no capabilities can be added.",
+				RuleID: ruleSecurityCapabilities, Field: "spec.ephemeralContainers[0].securityContext.capabilities.add"},
+		}, checkPod(t, r, debugged, &config{}))
+	})
+
+	t.Run("should extend allowed capabilities by namespace", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-security-capabilities-allowed-add": "NET_BIND_SERVICE"})
+		config, err := config.withNamespaceOverrides(&metav1.ObjectMeta{Annotations: map[string]string{
+			"admission.validation.avast.com/rule-security-capabilities-namespace-allowed-add": "NET_ADMIN,NET_RAW",
+		}})
+		assert.NoError(t, err)
+		violations := checkPod(t, r, pod, config)
+		assert.Len(t, violations, 2)
+		assert.Equal(t, "Container unrestricted", violations[0].TargetDesc)
+	})
+
+	t.Run("should normalize parameters with the config", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-security-capabilities-allowed-add":           "net_bind_service, CAP_NET_ADMIN",
+			"rule-security-capabilities-namespace-allowed-add": "NET_ADMIN",
+		})
+		assert.Equal(t, []string{"NET_BIND_SERVICE", "NET_ADMIN"}, config.capabilitiesAllowedNames)
+
+		derived, err := config.with(map[string]interface{}{"rule-security-capabilities-namespace-allowed-add": "NET_RAW"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"NET_BIND_SERVICE", "NET_ADMIN", "NET_RAW"}, derived.capabilitiesAllowedNames)
+		assert.Equal(t, []string{"NET_BIND_SERVICE", "NET_ADMIN"}, config.capabilitiesAllowedNames)
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		for _, settings := range []map[string]interface{}{
+			{"rule-security-capabilities-allowed-add": []string{""}},
+			{"rule-security-capabilities-allowed-add": "NET_ADMIN,,NET_RAW"},
+			{"rule-security-capabilities-namespace-allowed-add": "NET-RAW"},
+		} {
+			_, err := (&config{}).with(settings)
+			assert.Error(t, err, "%v", settings)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ruleSecurityRunAsNonRootRequired         = "security-run-as-non-root-required"
+	ruleSecurityPrivilegedForbidden          = "security-privileged-forbidden"
+	ruleSecurityPrivilegeEscalationForbidden = "security-privilege-escalation-forbidden"
+)
+
+type securityContextConfig struct {
+	RuleSecurityRunAsNonRootRequired                         bool `mapstructure:"rule-security-run-as-non-root-required"`
+	RuleSecurityRunAsNonRootRequiredWhitelistEnabled         bool `mapstructure:"rule-security-run-as-non-root-required-whitelist-enabled"`
+	RuleSecurityPrivilegedForbidden                          bool `mapstructure:"rule-security-privileged-forbidden"`
+	RuleSecurityPrivilegedForbiddenWhitelistEnabled          bool `mapstructure:"rule-security-privileged-forbidden-whitelist-enabled"`
+	RuleSecurityPrivilegeEscalationForbidden                 bool `mapstructure:"rule-security-privilege-escalation-forbidden"`
+	RuleSecurityPrivilegeEscalationForbiddenWhitelistEnabled bool `mapstructure:"rule-security-privilege-escalation-forbidden-whitelist-enabled"`
+}
+
+// Checks a single setting of container security contexts in every container,
+// init container and ephemeral container, unless the container is whitelisted
+// by the pod annotation named after the setting, like the readonly-rootfs rule
+// does.
+type securityContextRule struct {
+	id               string
+	description      string
+	whitelist        string
+	enabled          func(config *config) bool
+	whitelistEnabled func(config *config) bool
+	// returns the violation message and field name, or an empty message if the container complies
+	check func(podSpec *corev1.PodSpec, container *corev1.Container) (string, string)
+}
+
+var (
+	_ = registerRule(&securityContextRule{
+		ruleSecurityRunAsNonRootRequired,
+		"Whether 'runAsNonRoot: true' in pod or container security context specifications is required.",
+		"run-as-non-root-containers-whitelist",
+		func(config *config) bool { return config.RuleSecurityRunAsNonRootRequired },
+		func(config *config) bool { return config.RuleSecurityRunAsNonRootRequiredWhitelistEnabled },
+		checkRunAsNonRoot,
+	})
+	_ = registerRule(&securityContextRule{
+		ruleSecurityPrivilegedForbidden,
+		"Whether 'privileged: true' in security context specifications is forbidden.",
+		"privileged-containers-whitelist",
+		func(config *config) bool { return config.RuleSecurityPrivilegedForbidden },
+		func(config *config) bool { return config.RuleSecurityPrivilegedForbiddenWhitelistEnabled },
+		checkPrivileged,
+	})
+	_ = registerRule(&securityContextRule{
+		ruleSecurityPrivilegeEscalationForbidden,
+		"Whether 'allowPrivilegeEscalation: false' in security context specifications is required.",
+		"privilege-escalation-containers-whitelist",
+		func(config *config) bool { return config.RuleSecurityPrivilegeEscalationForbidden },
+		func(config *config) bool { return config.RuleSecurityPrivilegeEscalationForbiddenWhitelistEnabled },
+		checkPrivilegeEscalation,
+	})
+)
+
+func (r *securityContextRule) ID() string {
+	return r.id
+}
+
+func (r *securityContextRule) Description() string {
+	return r.description
+}
+
+func (r *securityContextRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *securityContextRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"whitelist-enabled", false, "Whether rule '" + r.id + "' can be ignored by container whitelisting."},
+	}
+}
+
+func (r *securityContextRule) Enabled(config *config) bool {
+	return r.enabled(config)
+}
+
+func (r *securityContextRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainerIncludingEphemeral(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		if r.whitelistEnabled(config) &&
+			containerWhitelisted(object.PodTemplate.PodMeta, r.whitelist, container.Name, config) {
+			return
+		}
+		msg, field := r.check(object.PodTemplate.PodSpec, container)
+		if msg == "" {
+			return
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    msg,
+			RuleID:     r.id,
+			Field:      path + ".securityContext." + field,
+		})
+	})
+	return violations, nil
+}
+
+// A container inherits 'runAsNonRoot' of the pod security context, unless it
+// sets its own.
+func checkRunAsNonRoot(podSpec *corev1.PodSpec, container *corev1.Container) (string, string) {
+	runAsNonRoot := podSpec.SecurityContext != nil && podSpec.SecurityContext.RunAsNonRoot != nil && *podSpec.SecurityContext.RunAsNonRoot
+	if container.SecurityContext != nil && container.SecurityContext.RunAsNonRoot != nil {
+		runAsNonRoot = *container.SecurityContext.RunAsNonRoot
+	}
+	if runAsNonRoot {
+		return "", ""
+	}
+	return "'securityContext' of the pod or container with 'runAsNonRoot: true' must be specified.", "runAsNonRoot"
+}
+
+func checkPrivileged(podSpec *corev1.PodSpec, container *corev1.Container) (string, string) {
+	if container.SecurityContext == nil || container.SecurityContext.Privileged == nil || !*container.SecurityContext.Privileged {
+		return "", ""
+	}
+	return "'securityContext' with 'privileged: true' is not allowed.", "privileged"
+}
+
+// Privilege escalation is allowed unless disabled explicitly.
+func checkPrivilegeEscalation(podSpec *corev1.PodSpec, container *corev1.Container) (string, string) {
+	securityContext := container.SecurityContext
+	if securityContext != nil && securityContext.AllowPrivilegeEscalation != nil && !*securityContext.AllowPrivilegeEscalation {
+		return "", ""
+	}
+	return "'securityContext' with 'allowPrivilegeEscalation: false' must be specified.", "allowPrivilegeEscalation"
+}
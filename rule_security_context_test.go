@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSecurityContextRules(t *testing.T) {
+	initLogger()
+
+	yes, no := true, false
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			"admission.validation.avast.com/run-as-non-root-containers-whitelist":      "init",
+			"admission.validation.avast.com/privileged-containers-whitelist":           "agent",
+			"admission.validation.avast.com/privilege-escalation-containers-whitelist": "agent, init",
+		}},
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{RunAsNonRoot: &yes},
+			Containers: []corev1.Container{
+				{Name: "app", SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &no}},
+				{Name: "agent", SecurityContext: &corev1.SecurityContext{RunAsNonRoot: &no, Privileged: &yes}},
+			},
+			InitContainers: []corev1.Container{{Name: "init", SecurityContext: &corev1.SecurityContext{RunAsNonRoot: &no}}},
+		},
+	}
+
+	t.Run("should require run as non-root inherited from the pod", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityRunAsNonRootRequired)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container agent", Message: "'securityContext' of the pod or container with 'runAsNonRoot: true' must be specified.",
+				RuleID: ruleSecurityRunAsNonRootRequired, Field: "spec.containers[1].securityContext.runAsNonRoot"},
+			{TargetDesc: "Init container init", Message: "'securityContext' of the pod or container with 'runAsNonRoot: true' must be specified.",
+				RuleID: ruleSecurityRunAsNonRootRequired, Field: "spec.initContainers[0].securityContext.runAsNonRoot"},
+		}, checkPod(t, r, pod, testConfig(t, nil)))
+
+		withoutPodContext := pod.DeepCopy()
+		withoutPodContext.Spec.SecurityContext = nil
+		assert.Len(t, checkPod(t, r, withoutPodContext, testConfig(t, nil)), 3)
+	})
+
+	t.Run("should forbid privileged containers", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityPrivilegedForbidden)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container agent", Message: "'securityContext' with 'privileged: true' is not allowed.",
+				RuleID: ruleSecurityPrivilegedForbidden, Field: "spec.containers[1].securityContext.privileged"},
+		}, checkPod(t, r, pod, testConfig(t, nil)))
+	})
+
+	t.Run("should require privilege escalation to be disabled", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityPrivilegeEscalationForbidden)
+		violations := checkPod(t, r, pod, testConfig(t, nil))
+		assert.Len(t, violations, 2)
+		assert.Equal(t, "Container agent", violations[0].TargetDesc)
+		assert.Equal(t, "spec.containers[1].securityContext.allowPrivilegeEscalation", violations[0].Field)
+		assert.Equal(t, "Init container init", violations[1].TargetDesc)
+	})
+
+	t.Run("should skip whitelisted containers", func(t *testing.T) {
+		for _, rule := range []string{ruleSecurityRunAsNonRootRequired, ruleSecurityPrivilegedForbidden, ruleSecurityPrivilegeEscalationForbidden} {
+			r := (&config{}).findRule(rule)
+			config := testConfig(t, map[string]interface{}{"rule-" + rule + "-whitelist-enabled": true})
+			violations := checkPod(t, r, pod, config)
+			if rule == ruleSecurityRunAsNonRootRequired {
+				assert.Len(t, violations, 1, rule)
+			} else {
+				assert.Empty(t, violations, rule)
+			}
+		}
+	})
+
+	t.Run("should check ephemeral containers", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityPrivilegedForbidden)
+		debugged := &corev1.Pod{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+			EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name: "debug", SecurityContext: &corev1.SecurityContext{Privileged: &yes},
+			}}},
+		}}
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Ephemeral container debug", Message: "'securityContext' with 'privileged: true' is not allowed.",
+				RuleID: ruleSecurityPrivilegedForbidden, Field: "spec.ephemeralContainers[0].securityContext.privileged"},
+		}, checkPod(t, r, debugged, testConfig(t, nil)))
+	})
+}
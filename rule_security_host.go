@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	ruleSecurityHostNamespacesForbidden = "security-host-namespaces-forbidden"
+	ruleSecurityHostPortsForbidden      = "security-host-ports-forbidden"
+)
+
+type hostConfig struct {
+	RuleSecurityHostNamespacesForbidden                  bool     `mapstructure:"rule-security-host-namespaces-forbidden"`
+	RuleSecurityHostNamespacesForbiddenAllowedNamespaces []string `mapstructure:"rule-security-host-namespaces-forbidden-allowed-namespaces"`
+	RuleSecurityHostPortsForbidden                       bool     `mapstructure:"rule-security-host-ports-forbidden"`
+	RuleSecurityHostPortsForbiddenAllowedNamespaces      []string `mapstructure:"rule-security-host-ports-forbidden-allowed-namespaces"`
+	RuleSecurityHostPortsForbiddenAllowedPorts           []string `mapstructure:"rule-security-host-ports-forbidden-allowed-ports"`
+}
+
+// Forbids pods sharing the network, PID or IPC namespace of the node, except
+// in the allowed namespaces.
+type hostNamespacesRule struct{}
+
+// Forbids container ports bound to the node, except in the allowed
+// namespaces and port ranges.
+type hostPortsRule struct{}
+
+var (
+	_ = registerRule(&hostNamespacesRule{})
+	_ = registerRule(&hostPortsRule{})
+)
+
+func (r *hostNamespacesRule) ID() string {
+	return ruleSecurityHostNamespacesForbidden
+}
+
+func (r *hostNamespacesRule) Description() string {
+	return "Whether 'hostNetwork', 'hostPID' and 'hostIPC' in pod specifications are forbidden."
+}
+
+func (r *hostNamespacesRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *hostNamespacesRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"allowed-namespaces", []string{}, "Namespaces, as names or regular expressions, whose objects can use host namespaces."},
+	}
+}
+
+func (r *hostNamespacesRule) Enabled(config *config) bool {
+	return config.RuleSecurityHostNamespacesForbidden
+}
+
+func (r *hostNamespacesRule) Validate(config *config) error {
+	allowedNamespaces, err := compileNamespacePatterns(config.RuleSecurityHostNamespacesForbiddenAllowedNamespaces)
+	if err != nil {
+		return fmt.Errorf("Invalid --rule-%s-allowed-namespaces %v", ruleSecurityHostNamespacesForbidden, err)
+	}
+	config.hostNamespacesAllowedNamespaces = allowedNamespaces
+	return nil
+}
+
+func (r *hostNamespacesRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	if matchesNamespace(config.hostNamespacesAllowedNamespaces, object.ObjMeta.Namespace) {
+		return nil, nil
+	}
+	podSpec := object.PodTemplate.PodSpec
+	targetDesc := fmt.Sprintf("%s %s", object.Kind, object.ObjMeta.Name)
+	var violations []validationViolation
+	for _, hostNamespace := range []struct {
+		field string
+		set   bool
+	}{
+		{"hostNetwork", podSpec.HostNetwork},
+		{"hostPID", podSpec.HostPID},
+		{"hostIPC", podSpec.HostIPC},
+	} {
+		if !hostNamespace.set {
+			continue
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: targetDesc,
+			Message:    fmt.Sprintf("'%s: true' is not allowed in namespace '%s'.", hostNamespace.field, object.ObjMeta.Namespace),
+			RuleID:     ruleSecurityHostNamespacesForbidden,
+			Field:      object.PodTemplate.SpecPath + "." + hostNamespace.field,
+		})
+	}
+	return violations, nil
+}
+
+func (r *hostPortsRule) ID() string {
+	return ruleSecurityHostPortsForbidden
+}
+
+func (r *hostPortsRule) Description() string {
+	return "Whether 'hostPort' in container port specifications is forbidden."
+}
+
+func (r *hostPortsRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *hostPortsRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"allowed-namespaces", []string{}, "Namespaces, as names or regular expressions, whose objects can use any host ports."},
+		{"allowed-ports", []string{}, "Host ports or port ranges allowed in any namespace, e.g. '9100,30000-32767'."},
+	}
+}
+
+func (r *hostPortsRule) Enabled(config *config) bool {
+	return config.RuleSecurityHostPortsForbidden
+}
+
+func (r *hostPortsRule) Validate(config *config) error {
+	allowedNamespaces, err := compileNamespacePatterns(config.RuleSecurityHostPortsForbiddenAllowedNamespaces)
+	if err != nil {
+		return fmt.Errorf("Invalid --rule-%s-allowed-namespaces %v", ruleSecurityHostPortsForbidden, err)
+	}
+	allowedPorts, err := parsePortRanges(config.RuleSecurityHostPortsForbiddenAllowedPorts)
+	if err != nil {
+		return fmt.Errorf("Invalid --rule-%s-allowed-ports: %v", ruleSecurityHostPortsForbidden, err)
+	}
+	config.hostPortsAllowedNamespaces, config.hostPortsAllowedPorts = allowedNamespaces, allowedPorts
+	return nil
+}
+
+// With 'hostNetwork: true' every container port is bound to the node, host
+// ports default to the container ports then.
+func (r *hostPortsRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	if matchesNamespace(config.hostPortsAllowedNamespaces, object.ObjMeta.Namespace) {
+		return nil, nil
+	}
+	allowedPorts := config.hostPortsAllowedPorts
+	hostNetwork := object.PodTemplate.PodSpec.HostNetwork
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		for i, port := range container.Ports {
+			hostPort := port.HostPort
+			if hostPort == 0 && hostNetwork {
+				hostPort = port.ContainerPort
+			}
+			if hostPort == 0 || allowedPorts.contain(hostPort) {
+				continue
+			}
+			msg := fmt.Sprintf("Host port %d is not allowed in namespace '%s'", hostPort, object.ObjMeta.Namespace)
+			if len(allowedPorts) == 0 {
+				msg += "."
+			} else {
+				msg += fmt.Sprintf(", allowed are '%s'.", strings.Join(config.RuleSecurityHostPortsForbiddenAllowedPorts, ","))
+			}
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    msg,
+				RuleID:     ruleSecurityHostPortsForbidden,
+				Field:      fmt.Sprintf("%s.ports[%d].hostPort", path, i),
+			})
+		}
+	})
+	return violations, nil
+}
+
+type portRange struct {
+	from int32
+	to   int32
+}
+
+type portRanges []portRange
+
+func (ranges portRanges) contain(port int32) bool {
+	for _, r := range ranges {
+		if port >= r.from && port <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses ports and port ranges, e.g. '9100' or '30000-32767'.
+func parsePortRanges(settings []string) (portRanges, error) {
+	var result portRanges
+	for _, setting := range settings {
+		bounds := strings.SplitN(setting, "-", 2)
+		var ports []int32
+		for _, bound := range bounds {
+			port, err := strconv.ParseInt(strings.TrimSpace(bound), 10, 32)
+			if err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("'%s' is not a port or a port range", setting)
+			}
+			ports = append(ports, int32(port))
+		}
+		r := portRange{ports[0], ports[len(ports)-1]}
+		if r.from > r.to {
+			return nil, fmt.Errorf("'%s' is not a port or a port range", setting)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
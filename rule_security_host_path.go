@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const ruleSecurityHostPathForbidden = "security-host-path-forbidden"
+
+type hostPathConfig struct {
+	RuleSecurityHostPathForbidden                 bool     `mapstructure:"rule-security-host-path-forbidden"`
+	RuleSecurityHostPathForbiddenAllowedPrefixes  []string `mapstructure:"rule-security-host-path-forbidden-allowed-prefixes"`
+	RuleSecurityHostPathForbiddenReadOnlyRequired bool     `mapstructure:"rule-security-host-path-forbidden-read-only-required"`
+}
+
+// Forbids 'hostPath' volumes outside the allowed path prefixes and,
+// optionally, their mounts which are not read-only, including those of
+// ephemeral containers.
+type hostPathRule struct{}
+
+var _ = registerRule(&hostPathRule{})
+
+func (r *hostPathRule) ID() string {
+	return ruleSecurityHostPathForbidden
+}
+
+func (r *hostPathRule) Description() string {
+	return "Whether 'hostPath' volumes are forbidden, except for the paths allowed by the rule's parameters."
+}
+
+func (r *hostPathRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *hostPathRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"allowed-prefixes", []string{}, "Host paths allowed to be mounted, including their subdirectories, e.g. '/var/log'."},
+		{"read-only-required", false, "Whether every mount of allowed 'hostPath' volumes must be 'readOnly: true'."},
+	}
+}
+
+func (r *hostPathRule) Enabled(config *config) bool {
+	return config.RuleSecurityHostPathForbidden
+}
+
+func (r *hostPathRule) Validate(config *config) error {
+	for _, prefix := range config.RuleSecurityHostPathForbiddenAllowedPrefixes {
+		if !path.IsAbs(strings.TrimSpace(prefix)) {
+			return fmt.Errorf("Invalid --rule-%s-allowed-prefixes: '%s' is not an absolute path", ruleSecurityHostPathForbidden, prefix)
+		}
+	}
+	return nil
+}
+
+func (r *hostPathRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	podSpec := object.PodTemplate.PodSpec
+	var violations []validationViolation
+	hostPathVolumes := map[string]bool{}
+	for i, volume := range podSpec.Volumes {
+		if volume.HostPath == nil {
+			continue
+		}
+		hostPathVolumes[volume.Name] = true
+		if hostPathAllowed(volume.HostPath.Path, config.RuleSecurityHostPathForbiddenAllowedPrefixes) {
+			continue
+		}
+		msg := fmt.Sprintf("'hostPath' '%s' is not allowed", volume.HostPath.Path)
+		if len(config.RuleSecurityHostPathForbiddenAllowedPrefixes) == 0 {
+			msg += "."
+		} else {
+			msg += fmt.Sprintf(", allowed are '%s'.", strings.Join(config.RuleSecurityHostPathForbiddenAllowedPrefixes, ","))
+		}
+		violations = append(violations, validationViolation{
+			TargetDesc: fmt.Sprintf("Volume %s", volume.Name),
+			Message:    msg,
+			RuleID:     ruleSecurityHostPathForbidden,
+			Field:      fmt.Sprintf("%s.volumes[%d].hostPath.path", object.PodTemplate.SpecPath, i),
+		})
+	}
+
+	if !config.RuleSecurityHostPathForbiddenReadOnlyRequired || len(hostPathVolumes) == 0 {
+		return violations, nil
+	}
+	forEachContainerVolumeMounts(object.PodTemplate, func(targetDesc string, path string, mounts []corev1.VolumeMount) {
+		for i, mount := range mounts {
+			if !hostPathVolumes[mount.Name] || mount.ReadOnly {
+				continue
+			}
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    fmt.Sprintf("Mount of 'hostPath' volume '%s' with 'readOnly: true' must be specified.", mount.Name),
+				RuleID:     ruleSecurityHostPathForbidden,
+				Field:      fmt.Sprintf("%s.volumeMounts[%d].readOnly", path, i),
+			})
+		}
+	})
+	return violations, nil
+}
+
+// Paths are compared cleaned, so that '..' cannot escape the prefix and
+// '/var/log' does not allow '/var/logs'.
+func hostPathAllowed(hostPath string, prefixes []string) bool {
+	hostPath = path.Clean("/" + hostPath)
+	for _, prefix := range prefixes {
+		prefix = path.Clean(strings.TrimSpace(prefix))
+		if hostPath == prefix || strings.HasPrefix(hostPath, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestHostPathRule(t *testing.T) {
+	initLogger()
+
+	r := (&config{}).findRule(ruleSecurityHostPathForbidden)
+	hostPath := func(name string, path string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: path}}}
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Volumes: []corev1.Volume{
+			hostPath("logs", "/var/log/pods"),
+			hostPath("docker", "/var/lib/docker"),
+			hostPath("escape", "/var/log/../../etc"),
+			hostPath("similar", "/var/logs"),
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		},
+		Containers: []corev1.Container{{Name: "collector", VolumeMounts: []corev1.VolumeMount{
+			{Name: "scratch", MountPath: "/tmp"},
+			{Name: "logs", MountPath: "/logs"},
+		}}},
+		InitContainers: []corev1.Container{{Name: "init", VolumeMounts: []corev1.VolumeMount{
+			{Name: "logs", MountPath: "/logs", ReadOnly: true},
+		}}},
+	}}
+
+	t.Run("should forbid host paths outside allowed prefixes", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-security-host-path-forbidden-allowed-prefixes": "/var/log"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Volume docker", Message: "'hostPath' '/var/lib/docker' is not allowed, allowed are '/var/log'.",
+				RuleID: ruleSecurityHostPathForbidden, Field: "spec.volumes[1].hostPath.path"},
+			{TargetDesc: "Volume escape", Message: "'hostPath' '/var/log/../../etc' is not allowed, allowed are '/var/log'.",
+				RuleID: ruleSecurityHostPathForbidden, Field: "spec.volumes[2].hostPath.path"},
+			{TargetDesc: "Volume similar", Message: "'hostPath' '/var/logs' is not allowed, allowed are '/var/log'.",
+				RuleID: ruleSecurityHostPathForbidden, Field: "spec.volumes[3].hostPath.path"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should forbid all host paths by default", func(t *testing.T) {
+		assert.Len(t, checkPod(t, r, pod, &config{}), 4)
+	})
+
+	t.Run("should require read-only mounts", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-security-host-path-forbidden-allowed-prefixes":   "/var/log,/var/lib/docker,/etc,/var/logs",
+			"rule-security-host-path-forbidden-read-only-required": true,
+		})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container collector", Message: "Mount of 'hostPath' volume 'logs' with 'readOnly: true' must be specified.",
+				RuleID: ruleSecurityHostPathForbidden, Field: "spec.containers[0].volumeMounts[1].readOnly"},
+		}, checkPod(t, r, pod, config))
+	})
+
+	t.Run("should require read-only mounts of ephemeral containers", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-security-host-path-forbidden-allowed-prefixes":   "/var/log",
+			"rule-security-host-path-forbidden-read-only-required": true,
+		})
+		debugged := &corev1.Pod{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{hostPath("logs", "/var/log/pods")},
+			EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+				Name: "debug", VolumeMounts: []corev1.VolumeMount{{Name: "logs", MountPath: "/logs"}},
+			}}},
+		}}
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Ephemeral container debug", Message: "Mount of 'hostPath' volume 'logs' with 'readOnly: true' must be specified.",
+				RuleID: ruleSecurityHostPathForbidden, Field: "spec.ephemeralContainers[0].volumeMounts[0].readOnly"},
+		}, checkPod(t, r, debugged, config))
+	})
+
+	t.Run("should reject relative prefixes", func(t *testing.T) {
+		_, err := (&config{}).with(map[string]interface{}{"rule-security-host-path-forbidden-allowed-prefixes": "var/log"})
+		assert.Error(t, err)
+	})
+}
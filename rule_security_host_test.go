@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHostRules(t *testing.T) {
+	initLogger()
+
+	pod := func(namespace string, hostNetwork bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: namespace},
+			Spec: corev1.PodSpec{
+				HostNetwork: hostNetwork,
+				HostPID:     true,
+				Containers: []corev1.Container{{Name: "exporter", Ports: []corev1.ContainerPort{
+					{ContainerPort: 8080},
+					{ContainerPort: 9100, HostPort: 9100},
+				}}},
+			},
+		}
+	}
+
+	t.Run("should forbid host namespaces", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityHostNamespacesForbidden)
+		config := testConfig(t, map[string]interface{}{"rule-security-host-namespaces-forbidden-allowed-namespaces": "monitoring,kube-.*"})
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Pod agent", Message: "'hostNetwork: true' is not allowed in namespace 'default'.",
+				RuleID: ruleSecurityHostNamespacesForbidden, Field: "spec.hostNetwork"},
+			{TargetDesc: "Pod agent", Message: "'hostPID: true' is not allowed in namespace 'default'.",
+				RuleID: ruleSecurityHostNamespacesForbidden, Field: "spec.hostPID"},
+		}, checkPod(t, r, pod("default", true), config))
+		assert.Empty(t, checkPod(t, r, pod("monitoring", true), config))
+		assert.Empty(t, checkPod(t, r, pod("kube-system", true), config))
+		assert.Len(t, checkPod(t, r, pod("kube", true), config), 2, "patterns match whole names")
+	})
+
+	t.Run("should forbid host ports outside allowed ranges", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityHostPortsForbidden)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container exporter", Message: "Host port 9100 is not allowed in namespace 'default'.",
+				RuleID: ruleSecurityHostPortsForbidden, Field: "spec.containers[0].ports[1].hostPort"},
+		}, checkPod(t, r, pod("default", false), &config{}))
+
+		config := testConfig(t, map[string]interface{}{"rule-security-host-ports-forbidden-allowed-ports": "9100-9110,443"})
+		assert.Empty(t, checkPod(t, r, pod("default", false), config))
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container exporter", Message: "Host port 8080 is not allowed in namespace 'default', allowed are '9100-9110,443'.",
+				RuleID: ruleSecurityHostPortsForbidden, Field: "spec.containers[0].ports[0].hostPort"},
+		}, checkPod(t, r, pod("default", true), config), "host network binds container ports")
+	})
+
+	t.Run("should allow host ports in allowed namespaces", func(t *testing.T) {
+		r := (&config{}).findRule(ruleSecurityHostPortsForbidden)
+		config := testConfig(t, map[string]interface{}{"rule-security-host-ports-forbidden-allowed-namespaces": "monitoring"})
+		assert.Empty(t, checkPod(t, r, pod("monitoring", true), config))
+	})
+
+	t.Run("should compile parameters with the config", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{
+			"rule-security-host-ports-forbidden-allowed-namespaces": "kube-.*",
+			"rule-security-host-ports-forbidden-allowed-ports":      "9100,30000-32767",
+		})
+		assert.Len(t, config.hostPortsAllowedNamespaces, 1)
+		assert.Equal(t, portRanges{{9100, 9100}, {30000, 32767}}, config.hostPortsAllowedPorts)
+
+		derived, err := config.with(map[string]interface{}{"rule-security-host-ports-forbidden-allowed-ports": "8080"})
+		assert.NoError(t, err)
+		assert.Equal(t, portRanges{{8080, 8080}}, derived.hostPortsAllowedPorts)
+		assert.Equal(t, portRanges{{9100, 9100}, {30000, 32767}}, config.hostPortsAllowedPorts)
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		for _, settings := range []map[string]interface{}{
+			{"rule-security-host-namespaces-forbidden-allowed-namespaces": "kube-("},
+			{"rule-security-host-ports-forbidden-allowed-namespaces": "kube-("},
+			{"rule-security-host-ports-forbidden-allowed-ports": "http"},
+			{"rule-security-host-ports-forbidden-allowed-ports": "9110-9100"},
+			{"rule-security-host-ports-forbidden-allowed-ports": "0-100"},
+		} {
+			_, err := (&config{}).with(settings)
+			assert.Error(t, err, "%v", settings)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const ruleSecurityReadonlyRootFilesystemRequired = "security-readonly-rootfs-required"
+
+// Requires 'readOnlyRootFilesystem: true' in every container and init
+// container, unless the container is whitelisted by the pod annotation.
+type readonlyRootFilesystemRule struct{}
+
+var _ = registerRule(&readonlyRootFilesystemRule{})
+
+func (r *readonlyRootFilesystemRule) ID() string {
+	return ruleSecurityReadonlyRootFilesystemRequired
+}
+
+func (r *readonlyRootFilesystemRule) Description() string {
+	return "Whether 'readOnlyRootFilesystem' in security context specifications is required."
+}
+
+func (r *readonlyRootFilesystemRule) Kinds() []string {
+	return podTemplateKinds
+}
+
+func (r *readonlyRootFilesystemRule) Parameters() []ruleParameter {
+	return []ruleParameter{
+		{"whitelist-enabled", false, "Whether rule 'readOnlyRootFilesystem' in security context can be ignored by container whitelisting."},
+	}
+}
+
+func (r *readonlyRootFilesystemRule) Enabled(config *config) bool {
+	return config.RuleSecurityReadonlyRootFilesystemRequired
+}
+
+func (r *readonlyRootFilesystemRule) Check(object *admittedObject, config *config) ([]validationViolation, error) {
+	var violations []validationViolation
+	forEachContainer(object.PodTemplate, func(targetDesc string, path string, container *corev1.Container) {
+		if !containerReadonlyFilesystemShouldBeChecked(object.PodTemplate.PodMeta, container.Name, config) {
+			return
+		}
+		securityContext := container.SecurityContext
+		if securityContext == nil || securityContext.ReadOnlyRootFilesystem == nil || !*securityContext.ReadOnlyRootFilesystem {
+			msg := "'securityContext' with 'readOnlyRootFilesystem: true' must be specified."
+			violations = append(violations, validationViolation{
+				TargetDesc: targetDesc,
+				Message:    msg,
+				RuleID:     ruleSecurityReadonlyRootFilesystemRequired,
+				Field:      path + ".securityContext.readOnlyRootFilesystem",
+			})
+		}
+	})
+	return violations, nil
+}
+
+func containerReadonlyFilesystemShouldBeChecked(podMetadata *metav1.ObjectMeta, containerName string, config *config) bool {
+	// If whitelisting of containers is turned off, validate each container
+	if !config.RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled {
+		return true
+	}
+	return !containerWhitelisted(podMetadata, "readonly-rootfs-containers-whitelist", containerName, config)
+}
+
+// Checks if container is whitelisted by the pod annotation of given name
+// (list of containers in one annotation).
+func containerWhitelisted(podMetadata *metav1.ObjectMeta, annotationName string, containerName string, config *config) bool {
+	annotation := prefixedAnnotation(config.AnnotationsPrefix, annotationName)
+	if annotationValue, ok := podMetadata.Annotations[annotation]; ok {
+		whitelistedContainers := strings.Split(annotationValue, ",")
+		for _, parsedContainerName := range whitelistedContainers {
+			parsedContainerName = strings.TrimSpace(parsedContainerName)
+			if parsedContainerName == containerName {
+				return true
+			}
+		}
+	}
+	return false
+}
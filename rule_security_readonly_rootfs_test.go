@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReadonlyRootFilesystemRule(t *testing.T) {
+	initLogger()
+
+	readonly := true
+	r := (&config{}).findRule(ruleSecurityReadonlyRootFilesystemRequired)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			"admission.validation.avast.com/readonly-rootfs-containers-whitelist": "sidecar, init",
+		}},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: &readonly}},
+				{Name: "sidecar"},
+			},
+			InitContainers: []corev1.Container{{Name: "init"}},
+		},
+	}
+
+	t.Run("should require read-only root filesystem", func(t *testing.T) {
+		violations := checkPod(t, r, pod, &config{AnnotationsPrefix: "admission.validation.avast.com"})
+		assert.Len(t, violations, 2)
+		assert.Equal(t, "Container sidecar", violations[0].TargetDesc)
+		assert.Equal(t, "Init container init", violations[1].TargetDesc)
+	})
+
+	t.Run("should skip whitelisted containers", func(t *testing.T) {
+		violations := checkPod(t, r, pod, &config{
+			AnnotationsPrefix: "admission.validation.avast.com",
+			RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled: true,
+		})
+		assert.Empty(t, violations)
+	})
+}
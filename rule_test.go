@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Returns a config with the settings, keyed like the flags, applied on top of
+// the default annotations prefix.
+func testConfig(t *testing.T, settings map[string]interface{}) *config {
+	config, err := (&config{AnnotationsPrefix: "admission.validation.avast.com"}).with(settings)
+	assert.NoError(t, err)
+	return config
+}
+
+// Returns the violations of the rule found in the pod.
+func checkPod(t *testing.T, r rule, pod *corev1.Pod, config *config) []validationViolation {
+	violations, err := r.Check(podObject(pod), config)
+	assert.NoError(t, err)
+	return violations
+}
+
+func TestRuleRegistry(t *testing.T) {
+	initLogger()
+
+	t.Run("should register every rule with its flags", func(t *testing.T) {
+		cmd := &cobra.Command{}
+		addRuleFlags(cmd)
+		assert.NotEmpty(t, ruleRegistry)
+		for _, r := range ruleRegistry {
+			assert.NotNil(t, cmd.Flags().Lookup("rule-"+r.ID()), r.ID())
+			assert.True(t, isConfigKey("rule-"+r.ID()), r.ID())
+			assert.NotEmpty(t, r.Kinds(), r.ID())
+			for _, parameter := range r.Parameters() {
+				assert.NotNil(t, cmd.Flags().Lookup("rule-"+r.ID()+"-"+parameter.Name), parameter.Name)
+				assert.True(t, isConfigKey("rule-"+r.ID()+"-"+parameter.Name), parameter.Name)
+			}
+		}
+	})
+
+	t.Run("should know kinds of active rules only", func(t *testing.T) {
+		config := &config{RuleIngressCollision: true, RuleResourceLimitCPURequired: true, RuleEnforcement: []string{"resource-limit-cpu-required=off"}}
+		assert.NoError(t, config.init())
+		assert.Equal(t, []string{"Ingress"}, config.activeKinds())
+		assert.False(t, config.kindActive("Pod"))
+	})
+
+	t.Run("should skip rules not applicable to the kind", func(t *testing.T) {
+		config := &config{RuleResourceLimitCPURequired: true, RuleIngressCollision: true}
+		validation := &objectValidation{"Pod", nil, &validationViolationSet{}}
+		pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "container"}}}}
+		assert.NoError(t, checkRules(validation, podObject(pod), config))
+		assert.Len(t, validation.Violations.Violations, 1)
+		assert.Equal(t, "resource-limit-cpu-required", validation.Violations.Violations[0].RuleID)
+	})
+}
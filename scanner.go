@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -37,8 +39,11 @@ func init() {
 }
 
 func scanCluster(cmd *cobra.Command, args []string) {
-	config := &config{}
-	if err := scannerViper.Unmarshal(config); err != nil {
+	config, err := loadConfig(scannerViper)
+	if err != nil {
+		errorWithUsage(err)
+	}
+	if config, err = config.withPolicyFile(); err != nil {
 		errorWithUsage(err)
 	}
 
@@ -48,64 +53,212 @@ func scanCluster(cmd *cobra.Command, args []string) {
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	config.namespaces = newNamespaceCache(kubeClientSet, 0)
 	log.Debugf("Init finished!")
 	
-	validatePods(kubeClientSet, config)
-	validateIngresses(kubeClientSet, config)	
+	if _, err := scan(kubeClientSet, config); err != nil {
+		log.Fatal(err.Error())
+	}
 
 	log.Debugf("Check completed!")
 }
 
-func validatePods(clientset *kubernetes.Clientset, config *config) {
-	log.Debugf("Check Pods...")
-
-	namespaceToScan := config.Namespace
-	pods, err := clientset.CoreV1().Pods(namespaceToScan).List(metav1.ListOptions{})
+// Checks objects of the same kinds as intercepted by the webhook, rules can be
+// enabled just by the policy profiles or namespace overrides. Returns the
+// validations of all the checked objects.
+func scan(clientSet kubernetes.Interface, config *config) ([]*objectValidation, error) {
+	kinds, err := config.registeredKinds()
 	if err != nil {
-		log.Fatal(err.Error())
+		return nil, err
 	}
-
-	if namespaceToScan == "" {
-		log.Debugf("There are %d pods in all namespaces", len(pods.Items))
-	} else {
-		log.Debugf("There are %d pods in the namespace '%s'", len(pods.Items), namespaceToScan)
+	var validations []*objectValidation
+	var unscannable []string
+	for _, kind := range kinds {
+		list, ok := scannedKinds[kind]
+		if !ok {
+			unscannable = append(unscannable, kind)
+			continue
+		}
+		kindValidations, err := scanKind(clientSet, config, kind, list)
+		if err != nil {
+			return nil, err
+		}
+		validations = append(validations, kindValidations...)
+	}
+	if len(unscannable) > 0 {
+		// e.g. kinds of CEL rules
+		log.Errorf("Kinds '%s' cannot be scanned, their rules are not checked", strings.Join(unscannable, "', '"))
 	}
+	return validations, nil
+}
+
+// Lists the objects of a kind in the namespace, all namespaces if empty.
+type objectLister func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error)
 
-	for _, pod := range pods.Items {
-		validation := &objectValidation{"Pod", nil, &validationViolationSet{}}
-		validatePodSpec(validation, &pod.ObjectMeta, &pod.Spec, config)
-		if len(validation.Violations.Violations) > 0 {
-			log.Debugf("Pod from namespace '%s' with name '%s' has following violations:", pod.ObjectMeta.Namespace, pod.ObjectMeta.Name)
-			for _, v := range validation.Violations.Violations {
-				log.Debugf("   %s", v.Message)
-			}
+// Kinds the scanner can list, by the kinds of rules.
+var scannedKinds = map[string]objectLister{
+	"Pod": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
 		}
-	}
+		var objects []*admittedObject
+		for i := range list.Items {
+			objects = append(objects, podObject(&list.Items[i]))
+		}
+		return objects, nil
+	},
+	"ReplicaSet": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.AppsV1().ReplicaSets(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("ReplicaSet", &item.ObjectMeta, &item.Spec.Template, "spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"Deployment": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("Deployment", &item.ObjectMeta, &item.Spec.Template, "spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"DaemonSet": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.AppsV1().DaemonSets(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("DaemonSet", &item.ObjectMeta, &item.Spec.Template, "spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"StatefulSet": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.AppsV1().StatefulSets(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("StatefulSet", &item.ObjectMeta, &item.Spec.Template, "spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"Job": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.BatchV1().Jobs(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("Job", &item.ObjectMeta, &item.Spec.Template, "spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"CronJob": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := clientSet.BatchV1beta1().CronJobs(namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			item := &list.Items[i]
+			objects = append(objects, workloadObject("CronJob", &item.ObjectMeta, &item.Spec.JobTemplate.Spec.Template, "spec.jobTemplate.spec.template.spec", item))
+		}
+		return objects, nil
+	},
+	"Ingress": func(clientSet kubernetes.Interface, namespace string) ([]*admittedObject, error) {
+		list, err := IngressClient(namespace, clientSet).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		var objects []*admittedObject
+		for i := range list.Items {
+			objects = append(objects, ingressObject(&list.Items[i]))
+		}
+		return objects, nil
+	},
 }
 
-func validateIngresses(clientset *kubernetes.Clientset, config *config) {
-	log.Debugf("Check Ingresses...")
+// Checks the listed objects of the kind and reports their violations, which
+// are returned as well.
+func scanKind(clientSet kubernetes.Interface, config *config, kind string, list objectLister) ([]*objectValidation, error) {
+	log.Debugf("Check %ss...", kind)
 
 	namespaceToScan := config.Namespace
-	ingresses, err := clientset.ExtensionsV1beta1().Ingresses(namespaceToScan).List(metav1.ListOptions{})
+	objects, err := list(clientSet, namespaceToScan)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
 
 	if namespaceToScan == "" {
-		log.Debugf("There are %d ingresses in all namespaces", len(ingresses.Items))
+		log.Debugf("There are %d %ss in all namespaces", len(objects), kind)
 	} else {
-		log.Debugf("There are %d ingresses in the namespace '%s'", len(ingresses.Items), namespaceToScan)
+		log.Debugf("There are %d %ss in the namespace '%s'", len(objects), kind, namespaceToScan)
+	}
+
+	var validations []*objectValidation
+	for _, object := range objects {
+		objMeta := object.ObjMeta
+		if reason := config.exclusionReason(objMeta.Namespace, nil); reason != "" {
+			log.Debugf("Skipping %s '%s/%s', %s", kind, objMeta.Namespace, objMeta.Name, reason)
+			continue
+		}
+		validation := &objectValidation{kind, objMeta, &validationViolationSet{}}
+		objectConfig := config.forNamespace(objMeta.Namespace)
+		object.ClientSet = clientSet
+		if err := checkRules(validation, object, objectConfig); err != nil {
+			log.Errorf("Could not check %s '%s/%s': %v", kind, objMeta.Namespace, objMeta.Name, err)
+			continue
+		}
+		exemption, exempted, err := exemptViolations(validation, object, objectConfig, time.Now())
+		if err != nil {
+			log.Warnf("Ignoring exemption of %s '%s/%s': %v", kind, objMeta.Namespace, objMeta.Name, err)
+		}
+		reportViolations(kind, objMeta, validation.Violations, objectConfig)
+		reportExemption(kind, objMeta, exemption, exempted)
+		validations = append(validations, validation)
 	}
+	return validations, nil
+}
 
-	for _, ingress := range ingresses.Items {
-		validation := &objectValidation{"Ingress", nil, &validationViolationSet{}}
-		ValidateIngress(validation, &ingress, config, clientset)
-		if len(validation.Violations.Violations) > 0 {
-			log.Debugf("Ingress from namespace '%s' with name '%s' has following violations:", ingress.ObjectMeta.Namespace, ingress.ObjectMeta.Name)
-			for _, v := range validation.Violations.Violations {
-				log.Debugf("   %s", v.Message)
-			}
+func reportViolations(kind string, objMeta *metav1.ObjectMeta, violations *validationViolationSet, config *config) {
+	denied, warned := violations.enforced(config)
+	if len(denied.Violations) > 0 || len(warned.Violations) > 0 {
+		log.Debugf("%s from namespace '%s' with name '%s' has following violations:", kind, objMeta.Namespace, objMeta.Name)
+		for _, v := range denied.Violations {
+			log.Debugf("   %s", v.Message)
+		}
+		for _, v := range warned.Violations {
+			log.Debugf("   %s (warning only)", v.Message)
 		}
 	}
 }
+
+// Reports exemptions, even those not needed anymore, to keep track of them.
+func reportExemption(kind string, objMeta *metav1.ObjectMeta, exemption *exemption, exempted *validationViolationSet) {
+	if exemption == nil {
+		return
+	}
+	if exemption.expired(time.Now()) {
+		log.Infof("%s from namespace '%s' with name '%s' has an expired exemption, %s", kind, objMeta.Namespace, objMeta.Name, exemption)
+		return
+	}
+	log.Infof("%s from namespace '%s' with name '%s' is %s, exempted violations:", kind, objMeta.Namespace, objMeta.Name, exemption)
+	for _, v := range exempted.Violations {
+		log.Infof("   %s", v.Message)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScan(t *testing.T) {
+	initLogger()
+
+	podSpec := corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"},
+		Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: podSpec}},
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "test"}, Spec: podSpec}
+
+	t.Run("should scan kinds of workload rules", func(t *testing.T) {
+		config := testConfig(t, map[string]interface{}{"rule-probe-readiness-required": true})
+		validations, err := scan(fake.NewSimpleClientset(deployment, pod), config)
+		assert.NoError(t, err)
+		assert.Len(t, validations, 1)
+		assert.Equal(t, "Deployment", validations[0].Kind)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Container app", Message: "'readinessProbe' must be specified.",
+				RuleID: "probe-readiness-required", Field: "spec.template.spec.containers[0].readinessProbe"},
+		}, validations[0].Violations.Violations)
+	})
+
+	t.Run("should scan kinds of CEL rules", func(t *testing.T) {
+		replicas := int32(20)
+		scaled := deployment.DeepCopy()
+		scaled.Spec.Replicas = &replicas
+		config, err := (&config{}).withPolicyData([]byte(`
+celRules:
+- id: replicas-bounded
+  kinds: [Deployment, ConfigMap]
+  expression: "object.spec.replicas <= 10"
+  message: "At most 10 replicas are allowed, not {{.object.spec.replicas}}."
+`))
+		assert.NoError(t, err)
+		validations, err := scan(fake.NewSimpleClientset(scaled, pod), config)
+		assert.NoError(t, err)
+		assert.Len(t, validations, 1)
+		assert.Equal(t, []validationViolation{
+			{TargetDesc: "Deployment app", Message: "At most 10 replicas are allowed, not 20.", RuleID: "replicas-bounded"},
+		}, validations[0].Violations.Violations)
+	})
+}
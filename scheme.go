@@ -1,6 +1,8 @@
 package main
 
 import (
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
@@ -18,6 +20,8 @@ func init() {
 }
 
 func addToScheme(scheme *runtime.Scheme) {
+	admissionv1.AddToScheme(scheme)
+	admissionv1beta1.AddToScheme(scheme)
 	appsv1.AddToScheme(scheme)
 	batchv1.AddToScheme(scheme)
 	batchv1beta1.AddToScheme(scheme)
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	secretCACertKey         = "ca.crt"
+	secretCAKeyKey          = "ca.key"
+	secretPreviousCACertKey = "ca-previous.crt"
+	// CA outlives many serving certificates, so that caBundle rarely changes
+	caValidityFactor = 10
+	// how often the stored certificates are checked for renewal
+	selfSignedTLSCheckInterval = time.Hour
+)
+
+// Generates a CA and a serving certificate, keeps them in a Secret shared by
+// all replicas, injects the CA into the webhook configurations and renews the
+// certificates before they expire.
+type selfSignedTLS struct {
+	clientSet          kubernetes.Interface
+	holder             *certificateHolder
+	serviceDNSName     string
+	secretNamespace    string
+	secretName         string
+	webhookConfigName  string
+	mutatingConfigName string
+	validity           time.Duration
+	renewBefore        time.Duration
+}
+
+type keyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newSelfSignedTLS(clientSet kubernetes.Interface, holder *certificateHolder, config *config) *selfSignedTLS {
+	return &selfSignedTLS{
+		clientSet:          clientSet,
+		holder:             holder,
+		serviceDNSName:     config.SelfSignedTLSServiceDNSName,
+		secretNamespace:    config.SelfSignedTLSSecretNamespace,
+		secretName:         config.SelfSignedTLSSecretName,
+		webhookConfigName:  config.WebhookConfigurationName,
+		mutatingConfigName: config.MutatingWebhookConfigurationName,
+		validity:           config.SelfSignedTLSValidity,
+		renewBefore:        config.SelfSignedTLSRenewBefore,
+	}
+}
+
+// Makes sure a valid certificate is stored, served and trusted by the API
+// server. Conflicting writes of other replicas are resolved by retrying, which
+// picks up what the other replica stored.
+func (s *selfSignedTLS) ensure() error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = s.ensureOnce(); err == nil || !(apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)) {
+			return err
+		}
+		log.Infof("Self-signed certificate was concurrently modified, retrying: %v", err)
+	}
+	return err
+}
+
+func (s *selfSignedTLS) ensureOnce() error {
+	secrets := s.clientSet.CoreV1().Secrets(s.secretNamespace)
+	secret, err := secrets.Get(context.TODO(), s.secretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = nil
+	} else if err != nil {
+		return err
+	}
+
+	var ca, serving *keyPair
+	var previousCACertPEM []byte
+	if secret != nil {
+		ca, _ = parseKeyPair(secret.Data[secretCACertKey], secret.Data[secretCAKeyKey])
+		serving, _ = parseKeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+		previousCACertPEM = secret.Data[secretPreviousCACertKey]
+	}
+
+	now := time.Now()
+	changed := false
+	if ca == nil || s.needsRenewal(ca.cert, now) {
+		if ca != nil {
+			previousCACertPEM = ca.certPEM
+		}
+		if ca, err = newCertificateAuthority(s.serviceDNSName, now, caValidityFactor*s.validity); err != nil {
+			return err
+		}
+		log.Infof("Generated new CA valid until %v", ca.cert.NotAfter)
+		serving = nil
+		changed = true
+	}
+	if serving == nil || s.needsRenewal(serving.cert, now) || serving.cert.VerifyHostname(s.serviceDNSName) != nil ||
+		serving.cert.CheckSignatureFrom(ca.cert) != nil {
+		if serving, err = issueServingCertificate(ca, s.serviceDNSName, now, s.validity); err != nil {
+			return err
+		}
+		log.Infof("Issued new serving certificate for %s valid until %v", s.serviceDNSName, serving.cert.NotAfter)
+		changed = true
+	}
+
+	if changed {
+		if err := s.storeSecret(secret, ca, serving, previousCACertPEM); err != nil {
+			return err
+		}
+	}
+
+	// the previous CA stays trusted until it expires, as other replicas might
+	// still serve a certificate it issued
+	caBundle := ca.certPEM
+	if previousCA, err := parseCertificate(previousCACertPEM); err == nil && now.Before(previousCA.NotAfter) {
+		caBundle = append(append([]byte{}, ca.certPEM...), previousCACertPEM...)
+	}
+	if err := s.injectCABundle(caBundle); err != nil {
+		return err
+	}
+
+	certificate, err := tls.X509KeyPair(serving.certPEM, serving.keyPEM)
+	if err != nil {
+		return err
+	}
+	if current := s.holder.get(); current == nil || !bytes.Equal(current.Certificate[0], certificate.Certificate[0]) {
+		return s.holder.set(&certificate)
+	}
+	return nil
+}
+
+func (s *selfSignedTLS) needsRenewal(cert *x509.Certificate, now time.Time) bool {
+	return now.Add(s.renewBefore).After(cert.NotAfter)
+}
+
+func (s *selfSignedTLS) storeSecret(secret *corev1.Secret, ca *keyPair, serving *keyPair, previousCACertPEM []byte) error {
+	secrets := s.clientSet.CoreV1().Secrets(s.secretNamespace)
+	data := map[string][]byte{
+		secretCACertKey:         ca.certPEM,
+		secretCAKeyKey:          ca.keyPEM,
+		corev1.TLSCertKey:       serving.certPEM,
+		corev1.TLSPrivateKeyKey: serving.keyPEM,
+	}
+	if previousCACertPEM != nil {
+		data[secretPreviousCACertKey] = previousCACertPEM
+	}
+
+	var err error
+	if secret == nil {
+		_, err = secrets.Create(context.TODO(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: s.secretName, Namespace: s.secretNamespace},
+			Data:       data,
+		}, metav1.CreateOptions{})
+	} else {
+		secret = secret.DeepCopy()
+		secret.Data = data
+		_, err = secrets.Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+	if err == nil {
+		log.Infof("Stored certificates in secret '%s/%s'", s.secretNamespace, s.secretName)
+	}
+	return err
+}
+
+func (s *selfSignedTLS) injectCABundle(caBundle []byte) error {
+	if s.webhookConfigName != "" {
+		configs := s.clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+		webhookConfig, err := configs.Get(context.TODO(), s.webhookConfigName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var clientConfigs []*admissionregistrationv1.WebhookClientConfig
+		for i := range webhookConfig.Webhooks {
+			clientConfigs = append(clientConfigs, &webhookConfig.Webhooks[i].ClientConfig)
+		}
+		if setCABundle(clientConfigs, caBundle) {
+			if _, err := configs.Update(context.TODO(), webhookConfig, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+			log.Infof("Injected CA bundle into ValidatingWebhookConfiguration '%s'", s.webhookConfigName)
+		}
+	}
+
+	if s.mutatingConfigName != "" {
+		configs := s.clientSet.AdmissionregistrationV1().MutatingWebhookConfigurations()
+		webhookConfig, err := configs.Get(context.TODO(), s.mutatingConfigName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		var clientConfigs []*admissionregistrationv1.WebhookClientConfig
+		for i := range webhookConfig.Webhooks {
+			clientConfigs = append(clientConfigs, &webhookConfig.Webhooks[i].ClientConfig)
+		}
+		if setCABundle(clientConfigs, caBundle) {
+			if _, err := configs.Update(context.TODO(), webhookConfig, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+			log.Infof("Injected CA bundle into MutatingWebhookConfiguration '%s'", s.mutatingConfigName)
+		}
+	}
+
+	return nil
+}
+
+// Returns whether any of the client configs had to be changed.
+func setCABundle(clientConfigs []*admissionregistrationv1.WebhookClientConfig, caBundle []byte) bool {
+	changed := false
+	for _, clientConfig := range clientConfigs {
+		if !bytes.Equal(clientConfig.CABundle, caBundle) {
+			clientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Periodically renews the certificates, errors are retried on the next check.
+func (s *selfSignedTLS) run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.ensure(); err != nil {
+			log.Errorf("Could not renew self-signed certificate: %v", err)
+		}
+	}
+}
+
+func newCertificateAuthority(serviceDNSName string, now time.Time, validity time.Duration) (*keyPair, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca@%d", serviceDNSName, now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return newKeyPair(template, nil)
+}
+
+func issueServingCertificate(ca *keyPair, serviceDNSName string, now time.Time, validity time.Duration) (*keyPair, error) {
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: serviceDNSName},
+		DNSNames:    []string{serviceDNSName},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return newKeyPair(template, ca)
+}
+
+// Creates a key and a certificate signed by the parent, or self-signed if the
+// parent is nil.
+func newKeyPair(template *x509.Certificate, parent *keyPair) (*keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serialNumber
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	)
+}
+
+func parseKeyPair(certPEM []byte, keyPEM []byte) (*keyPair, error) {
+	cert, err := parseCertificate(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, errors.New("missing PEM data of the key")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &keyPair{cert, key, certPEM, keyPEM}, nil
+}
+
+func parseCertificate(certPEM []byte) (*x509.Certificate, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, errors.New("missing PEM data of the certificate")
+	}
+	return x509.ParseCertificate(certBlock.Bytes)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSelfSignedTLS(t *testing.T) {
+	initLogger()
+
+	newTestSelfSignedTLS := func() (*selfSignedTLS, *fake.Clientset) {
+		clientSet := fake.NewSimpleClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8s-admission-webhook-cfg"},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "webhook.avast.com"}},
+		})
+		return newSelfSignedTLS(clientSet, &certificateHolder{}, &config{
+			SelfSignedTLSServiceDNSName:  "k8s-admission-webhook.default.svc",
+			SelfSignedTLSSecretNamespace: "default",
+			SelfSignedTLSSecretName:      "k8s-admission-webhook-tls",
+			SelfSignedTLSValidity:        24 * time.Hour,
+			SelfSignedTLSRenewBefore:     time.Hour,
+			WebhookConfigurationName:     "k8s-admission-webhook-cfg",
+		}), clientSet
+	}
+
+	t.Run("should generate certificates, store them and inject the CA", func(t *testing.T) {
+		selfSigned, clientSet := newTestSelfSignedTLS()
+		assert.NoError(t, selfSigned.ensure())
+
+		secret, err := clientSet.CoreV1().Secrets("default").Get(context.TODO(), "k8s-admission-webhook-tls", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, secret.Data["tls.crt"])
+		assert.NotEmpty(t, secret.Data["ca.key"])
+
+		webhookConfig, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), "k8s-admission-webhook-cfg", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, secret.Data["ca.crt"], webhookConfig.Webhooks[0].ClientConfig.CABundle)
+
+		roots := x509.NewCertPool()
+		assert.True(t, roots.AppendCertsFromPEM(webhookConfig.Webhooks[0].ClientConfig.CABundle))
+		served := selfSigned.holder.get()
+		assert.NotNil(t, served)
+		_, err = served.Leaf.Verify(x509.VerifyOptions{DNSName: "k8s-admission-webhook.default.svc", Roots: roots})
+		assert.NoError(t, err)
+	})
+
+	t.Run("should reuse stored certificates", func(t *testing.T) {
+		selfSigned, clientSet := newTestSelfSignedTLS()
+		assert.NoError(t, selfSigned.ensure())
+		served := selfSigned.holder.get()
+
+		other := newSelfSignedTLS(clientSet, &certificateHolder{}, &config{
+			SelfSignedTLSServiceDNSName:  selfSigned.serviceDNSName,
+			SelfSignedTLSSecretNamespace: selfSigned.secretNamespace,
+			SelfSignedTLSSecretName:      selfSigned.secretName,
+			SelfSignedTLSValidity:        selfSigned.validity,
+			SelfSignedTLSRenewBefore:     selfSigned.renewBefore,
+			WebhookConfigurationName:     selfSigned.webhookConfigName,
+		})
+		assert.NoError(t, other.ensure())
+		assert.Equal(t, served.Certificate[0], other.holder.get().Certificate[0])
+	})
+
+	t.Run("should renew the CA and keep trusting the previous one", func(t *testing.T) {
+		selfSigned, clientSet := newTestSelfSignedTLS()
+		assert.NoError(t, selfSigned.ensure())
+		oldCA, _ := clientSet.CoreV1().Secrets("default").Get(context.TODO(), "k8s-admission-webhook-tls", metav1.GetOptions{})
+
+		// CA is valid ten times longer than the serving certificate
+		selfSigned.renewBefore = 24 * 10 * time.Hour
+		assert.NoError(t, selfSigned.ensure())
+
+		secret, err := clientSet.CoreV1().Secrets("default").Get(context.TODO(), "k8s-admission-webhook-tls", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.NotEqual(t, oldCA.Data["ca.crt"], secret.Data["ca.crt"])
+		assert.Equal(t, oldCA.Data["ca.crt"], secret.Data["ca-previous.crt"])
+
+		webhookConfig, _ := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), "k8s-admission-webhook-cfg", metav1.GetOptions{})
+		assert.Equal(t, append(append([]byte{}, secret.Data["ca.crt"]...), oldCA.Data["ca.crt"]...), webhookConfig.Webhooks[0].ClientConfig.CABundle)
+	})
+}
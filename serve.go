@@ -9,12 +9,14 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
 	"k8s.io/api/admission/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
-type admitFunc func(v1beta1.AdmissionReview, *config, *kubernetes.Clientset) *v1beta1.AdmissionResponse
+type admitFunc func(admissionv1.AdmissionReview, *config, *kubernetes.Clientset) *admissionv1.AdmissionResponse
 
 func serve(w http.ResponseWriter, r *http.Request, admit admitFunc, config *config, clientSet *kubernetes.Clientset) {
 	var body []byte
@@ -32,29 +34,30 @@ func serve(w http.ResponseWriter, r *http.Request, admit admitFunc, config *conf
 	}
 
 	//	glog.V(2).Info(fmt.Sprintf("handling request: %v", body))
-	var reviewResponse *v1beta1.AdmissionResponse
-	ar := v1beta1.AdmissionReview{}
+	var response runtime.Object
 	deserializer := codecs.UniversalDeserializer()
-	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
-		log.Error(err)
-		reviewResponse = toAdmissionResponse(err)
-	}
-
-	response := v1beta1.AdmissionReview{}
-
-	if ar.Request != nil {
-		reviewResponse = admit(ar, config, clientSet)
-		log.Infof("sending response: %v", reviewResponse)
-
-		if reviewResponse != nil {
-			response.Response = reviewResponse
-			response.Response.UID = ar.Request.UID
-		}
-		// reset the Object and OldObject, they are not needed in a response.
-		ar.Request.Object = runtime.RawExtension{}
-		ar.Request.OldObject = runtime.RawExtension{}
+	// The API server sends the review in the first version listed in the webhook's
+	// admissionReviewVersions it supports and expects the response in the same one.
+	obj, gvk, err := deserializer.Decode(body, nil, nil)
+	if err != nil {
+		response = decodeErrorReview(body, err, config)
 	} else {
-		response.Response = toAdmissionResponse(fmt.Errorf("Invalid admission request"))
+		// the deserializer clears the type meta, but v1 responses must carry it
+		typeMeta := metav1.TypeMeta{APIVersion: gvk.GroupVersion().String(), Kind: gvk.Kind}
+		switch ar := obj.(type) {
+		case *admissionv1.AdmissionReview:
+			response = &admissionv1.AdmissionReview{
+				TypeMeta: typeMeta,
+				Response: admitReview(ar.Request, admit, config, clientSet),
+			}
+		case *v1beta1.AdmissionReview:
+			response = &v1beta1.AdmissionReview{
+				TypeMeta: typeMeta,
+				Response: toV1beta1AdmissionResponse(admitReview(toV1AdmissionRequest(ar.Request), admit, config, clientSet)),
+			}
+		default:
+			response = decodeErrorReview(body, fmt.Errorf("Unsupported admission review version: %v", gvk), config)
+		}
 	}
 
 	resp, err := json.Marshal(response)
@@ -66,16 +69,101 @@ func serve(w http.ResponseWriter, r *http.Request, admit admitFunc, config *conf
 	}
 }
 
-func toAdmissionResponse(err error) *v1beta1.AdmissionResponse {
-	return &v1beta1.AdmissionResponse{
+// Answers a review which could not be decoded in the version it was sent in,
+// as far as it can be read, or in v1beta1 otherwise.
+func decodeErrorReview(body []byte, err error, config *config) runtime.Object {
+	response := decodeErrorResponse("AdmissionReview", err, config)
+	partial := struct {
+		metav1.TypeMeta `json:",inline"`
+		Request         *struct {
+			UID types.UID `json:"uid"`
+		} `json:"request"`
+	}{}
+	if err := json.Unmarshal(body, &partial); err != nil {
+		log.Debugf("Could not read the version of the review: %v", err)
+	}
+	if partial.Request != nil {
+		response.UID = partial.Request.UID
+	}
+	if partial.APIVersion == admissionv1.SchemeGroupVersion.String() {
+		return &admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: partial.APIVersion, Kind: "AdmissionReview"},
+			Response: response,
+		}
+	}
+	return &v1beta1.AdmissionReview{Response: toV1beta1AdmissionResponse(response)}
+}
+
+func admitReview(request *admissionv1.AdmissionRequest, admit admitFunc, config *config, clientSet *kubernetes.Clientset) *admissionv1.AdmissionResponse {
+	if request == nil {
+		return toAdmissionResponse(fmt.Errorf("Invalid admission request"))
+	}
+
+	reviewResponse := admit(admissionv1.AdmissionReview{Request: request}, config, clientSet)
+	log.Infof("sending response: %v", reviewResponse)
+
+	if reviewResponse != nil {
+		reviewResponse.UID = request.UID
+	}
+	return reviewResponse
+}
+
+func toAdmissionResponse(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
 		Result: &metav1.Status{
 			Message: err.Error(),
 		},
 	}
 }
 
-func (fn admitFunc) serve(config *config, clientSet *kubernetes.Clientset) func(w http.ResponseWriter, r *http.Request) {
+// The v1beta1 and v1 admission types are structurally identical, so requests are
+// converted to v1 before being admitted and responses are converted back when
+// the API server talks v1beta1.
+func toV1AdmissionRequest(request *v1beta1.AdmissionRequest) *admissionv1.AdmissionRequest {
+	if request == nil {
+		return nil
+	}
+	return &admissionv1.AdmissionRequest{
+		UID:                request.UID,
+		Kind:               request.Kind,
+		Resource:           request.Resource,
+		SubResource:        request.SubResource,
+		RequestKind:        request.RequestKind,
+		RequestResource:    request.RequestResource,
+		RequestSubResource: request.RequestSubResource,
+		Name:               request.Name,
+		Namespace:          request.Namespace,
+		Operation:          admissionv1.Operation(request.Operation),
+		UserInfo:           request.UserInfo,
+		Object:             request.Object,
+		OldObject:          request.OldObject,
+		DryRun:             request.DryRun,
+		Options:            request.Options,
+	}
+}
+
+func toV1beta1AdmissionResponse(response *admissionv1.AdmissionResponse) *v1beta1.AdmissionResponse {
+	if response == nil {
+		return nil
+	}
+	var patchType *v1beta1.PatchType
+	if response.PatchType != nil {
+		pt := v1beta1.PatchType(*response.PatchType)
+		patchType = &pt
+	}
+	return &v1beta1.AdmissionResponse{
+		UID:              response.UID,
+		Allowed:          response.Allowed,
+		Result:           response.Result,
+		Patch:            response.Patch,
+		PatchType:        patchType,
+		AuditAnnotations: response.AuditAnnotations,
+		Warnings:         response.Warnings,
+	}
+}
+
+func (fn admitFunc) serve(configs *configStore, clientSet *kubernetes.Clientset) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		serve(w, r, fn, config, clientSet)
+		serve(w, r, fn, configs.get(), clientSet)
 	}
 }
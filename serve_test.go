@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func allowAll(ar admissionv1.AdmissionReview, config *config, clientSet *kubernetes.Clientset) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{Allowed: true}
+}
+
+func serveReview(review string) map[string]interface{} {
+	request := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewBufferString(review))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	serve(recorder, request, allowAll, &config{}, nil)
+
+	response := map[string]interface{}{}
+	json.Unmarshal(recorder.Body.Bytes(), &response)
+	return response
+}
+
+func TestServe(t *testing.T) {
+	initLogger()
+	for _, version := range []string{"admission.k8s.io/v1", "admission.k8s.io/v1beta1"} {
+		t.Run("should answer "+version+" review in the same version", func(t *testing.T) {
+			response := serveReview(`{
+				"apiVersion": "` + version + `",
+				"kind": "AdmissionReview",
+				"request": {"uid": "42", "kind": {"group": "", "version": "v1", "kind": "Pod"}, "operation": "CREATE"}
+			}`)
+			assert.Equal(t, version, response["apiVersion"])
+			assert.Equal(t, "AdmissionReview", response["kind"])
+			if assert.Contains(t, response, "response") {
+				reviewResponse := response["response"].(map[string]interface{})
+				assert.Equal(t, "42", reviewResponse["uid"])
+				assert.Equal(t, true, reviewResponse["allowed"])
+			}
+		})
+	}
+
+	t.Run("should reject review without request", func(t *testing.T) {
+		response := serveReview(`{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview"}`)
+		if assert.Contains(t, response, "response") {
+			reviewResponse := response["response"].(map[string]interface{})
+			assert.Equal(t, false, reviewResponse["allowed"])
+		}
+	})
+
+	t.Run("should answer undecodable review in its version and record it", func(t *testing.T) {
+		decodeErrorsBefore := testutil.ToFloat64(decodeErrors.WithLabelValues("AdmissionReview"))
+		response := serveReview(`{
+			"apiVersion": "admission.k8s.io/v1",
+			"kind": "AdmissionReview",
+			"request": {"uid": "42", "operation": 5}
+		}`)
+		assert.Equal(t, "admission.k8s.io/v1", response["apiVersion"])
+		assert.Equal(t, "AdmissionReview", response["kind"])
+		if assert.Contains(t, response, "response") {
+			reviewResponse := response["response"].(map[string]interface{})
+			assert.Equal(t, "42", reviewResponse["uid"])
+			assert.Equal(t, false, reviewResponse["allowed"])
+		}
+		assert.Equal(t, decodeErrorsBefore+1, testutil.ToFloat64(decodeErrors.WithLabelValues("AdmissionReview")))
+	})
+}
@@ -11,6 +11,13 @@ import (
 type validationViolation struct {
 	TargetDesc string
 	Message    string
+	RuleID     string
+	// JSON path of the violating field within the object, e.g.
+	// "spec.template.spec.containers[1].resources.limits.cpu", empty if the
+	// violation concerns the object as a whole
+	Field string
+	// Set from the enforcement level of the rule by enforced()
+	Severity enforcementLevel
 }
 
 type validationViolationSet struct {
@@ -23,114 +30,104 @@ type objectValidation struct {
 	Violations *validationViolationSet
 }
 
-func validatePodSpec(validation *objectValidation, podMetadata *metav1.ObjectMeta, podSpec *corev1.PodSpec, config *config) {
-	var containerDescription string
-	for _, container := range podSpec.Containers {
-		containerDescription = fmt.Sprintf("Container %s", container.Name)
-
-		validateContainerResources(validation, containerDescription, &container, config)
-		validateContainerSecurityContext(validation, podMetadata, containerDescription, &container, config)
+// Calls fn for every container and init container of the pod template, with
+// the description to be used as the target of violations and the path of the
+// container within the object.
+func forEachContainer(template *podTemplate, fn func(targetDesc string, path string, container *corev1.Container)) {
+	podSpec := template.PodSpec
+	for i := range podSpec.Containers {
+		fn(fmt.Sprintf("Container %s", podSpec.Containers[i].Name), fmt.Sprintf("%s.containers[%d]", template.SpecPath, i), &podSpec.Containers[i])
 	}
-	for _, container := range podSpec.InitContainers {
-		containerDescription = fmt.Sprintf("Init container %s", container.Name)
-
-		validateContainerResources(validation, containerDescription, &container, config)
-		validateContainerSecurityContext(validation, podMetadata, containerDescription, &container, config)
+	for i := range podSpec.InitContainers {
+		fn(fmt.Sprintf("Init container %s", podSpec.InitContainers[i].Name), fmt.Sprintf("%s.initContainers[%d]", template.SpecPath, i), &podSpec.InitContainers[i])
 	}
 }
 
-func validateContainerResources(validation *objectValidation, targetDesc string, container *corev1.Container, config *config) {
-	validateResource(validation.Violations, targetDesc,
-		container.Resources.Limits, "limit", corev1.ResourceCPU,
-		config.RuleResourceLimitCPURequired, config.RuleResourceLimitCPUMustBeNonZero)
-	validateResource(validation.Violations, targetDesc,
-		container.Resources.Limits, "limit", corev1.ResourceMemory,
-		config.RuleResourceLimitMemoryRequired, config.RuleResourceLimitMemoryMustBeNonZero)
-	validateResource(validation.Violations, targetDesc,
-		container.Resources.Requests, "request", corev1.ResourceCPU,
-		config.RuleResourceRequestCPURequired, config.RuleResourceRequestCPUMustBeNonZero)
-	validateResource(validation.Violations, targetDesc,
-		container.Resources.Requests, "request", corev1.ResourceMemory,
-		config.RuleResourceRequestMemoryRequired, config.RuleResourceRequestMemoryMustBeNonZero)
-}
-
-func validateContainerSecurityContext(validation *objectValidation, podMetadata *metav1.ObjectMeta, targetDesc string, container *corev1.Container, config *config) {
-	if containerReadonlyFilesystemShouldBeChecked(podMetadata, container.Name, config) {
-		validateContainerReadonlyFilesystem(validation, targetDesc, container.SecurityContext)
+// Calls fn for every container, init container and ephemeral container of the
+// pod template, like forEachContainer. Ephemeral containers are passed as
+// copies converted to containers, their fields are kept in sync by Kubernetes.
+func forEachContainerIncludingEphemeral(template *podTemplate, fn func(targetDesc string, path string, container *corev1.Container)) {
+	forEachContainer(template, fn)
+	podSpec := template.PodSpec
+	for i := range podSpec.EphemeralContainers {
+		container := corev1.Container(podSpec.EphemeralContainers[i].EphemeralContainerCommon)
+		fn(fmt.Sprintf("Ephemeral container %s", container.Name), fmt.Sprintf("%s.ephemeralContainers[%d]", template.SpecPath, i), &container)
 	}
 }
 
-func validateContainerReadonlyFilesystem(validation *objectValidation, targetDesc string, securityContext *corev1.SecurityContext) {
-	if securityContext == nil || securityContext.ReadOnlyRootFilesystem == nil || !*securityContext.ReadOnlyRootFilesystem {
-		msg := "'securityContext' with 'readOnlyRootFilesystem: true' must be specified."
-		validation.Violations.add(validationViolation{targetDesc, msg})
-	}
+// Calls fn for the image of every container, including ephemeral ones.
+// Ephemeral containers have no resources or ports, but run images like any
+// other container.
+func forEachContainerImage(template *podTemplate, fn func(targetDesc string, path string, image string, pullPolicy corev1.PullPolicy)) {
+	forEachContainerIncludingEphemeral(template, func(targetDesc string, path string, container *corev1.Container) {
+		fn(targetDesc, path, container.Image, container.ImagePullPolicy)
+	})
 }
 
-func validateResource(violationSet *validationViolationSet, targetDesc string, resList corev1.ResourceList,
-	listName string, name corev1.ResourceName, validateIsSet bool, validateIsNonZero bool) {
-	if validateIsSet && !isResourceSet(resList, name) {
-		msg := fmt.Sprintf("'%s' resource %s must be specified.", name, listName)
-		violationSet.add(validationViolation{targetDesc, msg})
-	}
-	if validateIsNonZero && !isResourceNonZero(resList, name) {
-		msg := fmt.Sprintf("'%s' resource %s must be a nonzero value.", name, listName)
-		violationSet.add(validationViolation{targetDesc, msg})
-	}
+// Calls fn for the security context of every container, including ephemeral
+// ones. The security context is nil unless specified.
+func forEachContainerSecurityContext(template *podTemplate, fn func(targetDesc string, path string, securityContext *corev1.SecurityContext)) {
+	forEachContainerIncludingEphemeral(template, func(targetDesc string, path string, container *corev1.Container) {
+		fn(targetDesc, path, container.SecurityContext)
+	})
 }
 
-func isResourceSet(resList corev1.ResourceList, name corev1.ResourceName) bool {
-	var missing = resList == nil
-	if !missing {
-		if _, ok := resList[name]; !ok {
-			missing = true
-		}
-	}
-	return !missing
+// Calls fn for the volume mounts of every container, including ephemeral ones.
+func forEachContainerVolumeMounts(template *podTemplate, fn func(targetDesc string, path string, mounts []corev1.VolumeMount)) {
+	forEachContainerIncludingEphemeral(template, func(targetDesc string, path string, container *corev1.Container) {
+		fn(targetDesc, path, container.VolumeMounts)
+	})
 }
 
-func isResourceNonZero(resList corev1.ResourceList, name corev1.ResourceName) bool {
-	if resList == nil {
-		return true
-	}
-	if r, ok := resList[name]; ok {
-		return !r.IsZero()
-	} else {
-		return true
+func prefixedAnnotation(annotationsPrefix string, annotation string) string {
+	if annotationsPrefix != "" {
+		return annotationsPrefix + "/" + annotation
 	}
+	return annotation
 }
 
-func containerReadonlyFilesystemShouldBeChecked(podMetadata *metav1.ObjectMeta, containerName string, config *config) bool {
-	// If readonly root FS check is turned off, do not validate
-	if !config.RuleSecurityReadonlyRootFilesystemRequired {
-		return false
-	}
+func (violationSet *validationViolationSet) add(violation validationViolation) {
+	violationSet.Violations = append(violationSet.Violations, violation)
+}
 
-	// If whitelisting of containers is turned off, validate each container
-	if !config.RuleSecurityReadonlyRootFilesystemRequiredWhitelistEnabled {
-		return true
+// Splits violations by the enforcement level of their rules. Violations of
+// rules turned off are dropped.
+func (violationSet *validationViolationSet) enforced(config *config) (denied *validationViolationSet, warned *validationViolationSet) {
+	denied, warned = &validationViolationSet{}, &validationViolationSet{}
+	for _, v := range violationSet.Violations {
+		v.Severity = config.enforcement(v.RuleID)
+		switch v.Severity {
+		case enforcementDeny:
+			denied.add(v)
+		case enforcementWarn:
+			warned.add(v)
+		}
 	}
+	return
+}
 
-	// Check if container is whitelisted by annotation (list of containers in one annotation)
-	annotation := "readonly-rootfs-containers-whitelist"
-	if config.AnnotationsPrefix != "" {
-		annotation = config.AnnotationsPrefix + "/" + annotation
-	}
-	if annotationValue, ok := podMetadata.Annotations[annotation]; ok {
-		whitelistedContainers := strings.Split(annotationValue, ",")
-		for _, parsedContainerName := range whitelistedContainers {
-			parsedContainerName = strings.TrimSpace(parsedContainerName)
-			if parsedContainerName == containerName {
-				return false
-			}
-		}
+// Returns one line per violation, to be used as admission warnings.
+func (violationSet *validationViolationSet) warnings() []string {
+	var warnings []string
+	for _, v := range violationSet.Violations {
+		warnings = append(warnings, fmt.Sprintf("%s: %s", v.TargetDesc, v.Message))
 	}
-	
-	return true
+	return warnings
 }
 
-func (violationSet *validationViolationSet) add(violation validationViolation) {
-	violationSet.Violations = append(violationSet.Violations, violation)
+// Returns one cause per violation, for tools to tell the violations apart
+// without parsing the message. The type of the cause is the rule ID and its
+// message starts with the severity, e.g. "deny: Container app: ...".
+func (violationSet *validationViolationSet) causes() []metav1.StatusCause {
+	var causes []metav1.StatusCause
+	for _, v := range violationSet.Violations {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseType(v.RuleID),
+			Message: fmt.Sprintf("%s: %s: %s", v.Severity, v.TargetDesc, v.Message),
+			Field:   v.Field,
+		})
+	}
+	return causes
 }
 
 // Returns the textual representation of a validation set. It groups
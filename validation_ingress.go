@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	extv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +32,21 @@ type PathDefinition struct {
 	ingressNamespace string
 }
 
+// Paths of the host and of the path of a definition within its ingress,
+// violations of the definition point at them.
+type definitionFields struct {
+	host string
+	path string
+}
+
+// Returns the fields of the i-th definition, empty if they are not known.
+func definitionFieldsAt(fields []definitionFields, i int) definitionFields {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return definitionFields{}
+}
+
 func (pathDefinition *PathDefinition) toUri() string {
 	return pathDefinition.host + pathDefinition.path
 }
@@ -38,54 +55,61 @@ func (pathDefinition *PathDefinition) toServiceTarget() string {
 	return pathDefinition.serviceName + "." + pathDefinition.ingressNamespace + ":" + pathDefinition.servicePort
 }
 
-func ValidateIngress(validation *objectValidation, ingress *extv1beta1.Ingress, config *config, clientSet *kubernetes.Clientset) error {
+func ValidateIngress(validation *objectValidation, ingress *extv1beta1.Ingress, config *config, clientSet kubernetes.Interface) error {
 
 	if config.RuleIngressCollision {
 		targetDesc := fmt.Sprintf("Ingress %s.%s: ", ingress.Name, ingress.Namespace)
 
-		existingIngresses, err := IngressClientAllNamespaces(clientSet).List(metav1.ListOptions{})
+		timer := prometheus.NewTimer(ingressListDuration)
+		existingIngresses, err := IngressClientAllNamespaces(clientSet).List(context.TODO(), metav1.ListOptions{})
+		timer.ObserveDuration()
 		if err != nil {
 			return err
 		}
 		log.Debugf("There are %d ingresses in the cluster to be compared", len(existingIngresses.Items))
 
-		newIngressPathData := ingressPath(ingress)
-		newIngressTlsData := ingressTls(ingress)
+		newIngressPathData, newIngressPathFields := ingressPath(ingress)
+		newIngressTlsData, newIngressTlsFields := ingressTls(ingress)
 
-		ValidatePathDataRegex(newIngressPathData, validation, targetDesc)
-		ValidateTlsDataRegex(newIngressTlsData, validation, targetDesc)
+		ValidatePathDataRegex(newIngressPathData, validation, targetDesc, newIngressPathFields...)
+		ValidateTlsDataRegex(newIngressTlsData, validation, targetDesc, newIngressTlsFields...)
 
 		var existingIngressesPathData []PathDefinition
 		for _, existingIngress := range existingIngresses.Items {
-			existingIngressesPathData = append(existingIngressesPathData, ingressPath(&existingIngress)...)
+			pathData, _ := ingressPath(&existingIngress)
+			existingIngressesPathData = append(existingIngressesPathData, pathData...)
 		}
-		ValidatePathDataCollision(newIngressPathData, existingIngressesPathData, validation, targetDesc)
+		ValidatePathDataCollision(newIngressPathData, existingIngressesPathData, validation, targetDesc, newIngressPathFields...)
 
 		var existingIngressesTlsData []TlsDefinition
 		for _, existingIngress := range existingIngresses.Items {
-			existingIngressesTlsData = append(existingIngressesTlsData, ingressTls(&existingIngress)...)
+			tlsData, _ := ingressTls(&existingIngress)
+			existingIngressesTlsData = append(existingIngressesTlsData, tlsData...)
 		}
-		ValidateTlsDataCollision(newIngressTlsData, existingIngressesTlsData, validation, targetDesc)
+		ValidateTlsDataCollision(newIngressTlsData, existingIngressesTlsData, validation, targetDesc, newIngressTlsFields...)
 	}
 	return nil
 }
 
-func ValidateTlsDataRegex(tlsDefinition []TlsDefinition, validation *objectValidation, targetDesc string) {
-	for _, tls := range tlsDefinition {
-		validateHost(tls.host, validation, targetDesc)
+// The optional fields belong to the definitions at the same positions.
+func ValidateTlsDataRegex(tlsDefinition []TlsDefinition, validation *objectValidation, targetDesc string, fields ...definitionFields) {
+	for i, tls := range tlsDefinition {
+		validateHost(tls.host, definitionFieldsAt(fields, i).host, validation, targetDesc)
 	}
 }
 
-func ValidateTlsDataCollision(newIngressTlsData []TlsDefinition, existingIngressesTlsData []TlsDefinition, validation *objectValidation, targetDesc string) {
-	for _, newTls := range newIngressTlsData {
+func ValidateTlsDataCollision(newIngressTlsData []TlsDefinition, existingIngressesTlsData []TlsDefinition, validation *objectValidation, targetDesc string, fields ...definitionFields) {
+	for i, newTls := range newIngressTlsData {
 		for _, existingTls := range existingIngressesTlsData {
 			if newTls.host == existingTls.host {
 				//if hosts are identical then also secret name and namespace has to match
 				if !(newTls.ingressNamespace == existingTls.ingressNamespace && newTls.secretName == existingTls.secretName) {
 					validation.Violations.add(
 						validationViolation{
-							targetDesc,
-							fmt.Sprintf("TLS collision with '%s.%s' on '%s'", existingTls.ingressName, existingTls.ingressNamespace, existingTls.host),
+							TargetDesc: targetDesc,
+							Message:    fmt.Sprintf("TLS collision with '%s.%s' on '%s'", existingTls.ingressName, existingTls.ingressNamespace, existingTls.host),
+							RuleID:     ruleIngressCollision,
+							Field:      definitionFieldsAt(fields, i).host,
 						},
 					)
 				}
@@ -94,23 +118,26 @@ func ValidateTlsDataCollision(newIngressTlsData []TlsDefinition, existingIngress
 	}
 }
 
-func ValidatePathDataRegex(pathData []PathDefinition, validation *objectValidation, targetDesc string) {
-	for _, path := range pathData {
-		validatePath(path.path, validation, targetDesc)
-		validateHost(path.host, validation, targetDesc)
+// The optional fields belong to the definitions at the same positions.
+func ValidatePathDataRegex(pathData []PathDefinition, validation *objectValidation, targetDesc string, fields ...definitionFields) {
+	for i, path := range pathData {
+		validatePath(path.path, definitionFieldsAt(fields, i).path, validation, targetDesc)
+		validateHost(path.host, definitionFieldsAt(fields, i).host, validation, targetDesc)
 	}
 }
 
-func ValidatePathDataCollision(newIngressPathData []PathDefinition, existingIngressPathData []PathDefinition, validation *objectValidation, targetDesc string) {
-	for _, newIngressPath := range newIngressPathData {
+func ValidatePathDataCollision(newIngressPathData []PathDefinition, existingIngressPathData []PathDefinition, validation *objectValidation, targetDesc string, fields ...definitionFields) {
+	for i, newIngressPath := range newIngressPathData {
 		for _, existingIngressPath := range existingIngressPathData {
 			// only other ingresses are considered - when updating it's not a collision
 			if nameWithNamespace(newIngressPath) != nameWithNamespace(existingIngressPath) {
 				if newIngressPath.toUri() == existingIngressPath.toUri() {
 					if newIngressPath.toServiceTarget() != existingIngressPath.toServiceTarget() {
 						violation := validationViolation{
-							targetDesc,
-							fmt.Sprintf("Path collision with '%s' -> '%s'", existingIngressPath.toUri(), existingIngressPath.toServiceTarget()),
+							TargetDesc: targetDesc,
+							Message:    fmt.Sprintf("Path collision with '%s' -> '%s'", existingIngressPath.toUri(), existingIngressPath.toServiceTarget()),
+							RuleID:     ruleIngressCollision,
+							Field:      definitionFieldsAt(fields, i).path,
 						}
 						validation.Violations.add(violation)
 					}
@@ -124,11 +151,11 @@ func nameWithNamespace(pathDefinition PathDefinition) string {
 	return pathDefinition.ingressName + "." + pathDefinition.ingressNamespace
 }
 
-func ingressPath(ingress *extv1beta1.Ingress) (result []PathDefinition) {
-	for _, rule := range ingress.Spec.Rules {
+func ingressPath(ingress *extv1beta1.Ingress) (result []PathDefinition, fields []definitionFields) {
+	for i, rule := range ingress.Spec.Rules {
 		host := rule.Host
 		if rule.HTTP != nil {
-			for _, path := range rule.HTTP.Paths {
+			for j, path := range rule.HTTP.Paths {
 
 				pathValue := path.Path
 				if pathValue == "" {
@@ -144,6 +171,10 @@ func ingressPath(ingress *extv1beta1.Ingress) (result []PathDefinition) {
 					ingressNamespace: ingress.Namespace,
 				}
 				result = append(result, pathDefinition)
+				fields = append(fields, definitionFields{
+					host: fmt.Sprintf("spec.rules[%d].host", i),
+					path: fmt.Sprintf("spec.rules[%d].http.paths[%d].path", i, j),
+				})
 			}
 		} else {
 			log.Warnf("No http definition for %s.%s in rule %v", ingress.Name, ingress.Namespace, rule)
@@ -152,27 +183,28 @@ func ingressPath(ingress *extv1beta1.Ingress) (result []PathDefinition) {
 	return
 }
 
-func ingressTls(ingress *extv1beta1.Ingress) (result []TlsDefinition) {
-	for _, tls := range ingress.Spec.TLS {
-		for _, host := range tls.Hosts {
+func ingressTls(ingress *extv1beta1.Ingress) (result []TlsDefinition, fields []definitionFields) {
+	for i, tls := range ingress.Spec.TLS {
+		for j, host := range tls.Hosts {
 			tlsDefinition := TlsDefinition{host, tls.SecretName, ingress.Name, ingress.Namespace}
 			result = append(result, tlsDefinition)
+			fields = append(fields, definitionFields{host: fmt.Sprintf("spec.tls[%d].hosts[%d]", i, j)})
 		}
 	}
 	return
 }
 
-func validateHost(host string, validation *objectValidation, targetDesc string) {
+func validateHost(host string, field string, validation *objectValidation, targetDesc string) {
 	if !ingressHostRegExp.MatchString(host) {
-		validation.Violations.add(validationViolation{targetDesc, fmt.Sprintf("Host '%s' is not valid", host)})
+		validation.Violations.add(validationViolation{TargetDesc: targetDesc, Message: fmt.Sprintf("Host '%s' is not valid", host), RuleID: ruleIngressCollision, Field: field})
 	}
 }
 
-func validatePath(path string, validation *objectValidation, targetDesc string) {
+func validatePath(path string, field string, validation *objectValidation, targetDesc string) {
 	valid := strings.HasPrefix(path, "/")
 	valid = valid && ingressPathRegExp.MatchString(path)
 	if !valid {
-		validation.Violations.add(validationViolation{targetDesc, fmt.Sprintf("Path '%s' is not valid", path)})
+		validation.Violations.add(validationViolation{TargetDesc: targetDesc, Message: fmt.Sprintf("Path '%s' is not valid", path), RuleID: ruleIngressCollision, Field: field})
 
 	}
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var incompletePod = `{
+	"metadata": {"name": "pod", "namespace": "test"},
+	"spec": {"containers": [{"name": "container"}]}
+}`
+
+func admissionReview(kind string, object string) admissionv1.AdmissionReview {
+	ar := admissionv1.AdmissionReview{Request: &admissionv1.AdmissionRequest{}}
+	ar.Request.Kind.Kind = kind
+	ar.Request.Object = runtime.RawExtension{Raw: []byte(object)}
+	return ar
+}
+
+func TestEnforcement(t *testing.T) {
+	initLogger()
+
+	newConfig := func(enforcement ...string) *config {
+		config := &config{
+			RuleResourceLimitCPURequired:               true,
+			RuleSecurityReadonlyRootFilesystemRequired: true,
+			RuleEnforcement:                            enforcement,
+		}
+		assert.NoError(t, config.init())
+		return config
+	}
+
+	t.Run("should deny by default", func(t *testing.T) {
+		response := validate(admissionReview("Pod", incompletePod), newConfig(), nil)
+		assert.False(t, response.Allowed)
+		assert.Contains(t, response.Result.Message, "'cpu' resource limit must be specified")
+		assert.Contains(t, response.Result.Message, "'readOnlyRootFilesystem: true' must be specified")
+		assert.Empty(t, response.Warnings)
+	})
+
+	t.Run("should only warn about rules in warn mode", func(t *testing.T) {
+		response := validate(admissionReview("Pod", incompletePod), newConfig(" resource-limit-cpu-required = warn"), nil)
+		assert.False(t, response.Allowed)
+		assert.NotContains(t, response.Result.Message, "'cpu' resource limit must be specified")
+		assert.Equal(t, []string{"Container container: 'cpu' resource limit must be specified."}, response.Warnings)
+	})
+
+	t.Run("should allow when all violated rules are in warn mode or off", func(t *testing.T) {
+		response := validate(admissionReview("Pod", incompletePod),
+			newConfig("resource-limit-cpu-required=warn", "security-readonly-rootfs-required=off"), nil)
+		assert.True(t, response.Allowed)
+		assert.Len(t, response.Warnings, 1)
+	})
+
+	t.Run("should reject invalid enforcement settings", func(t *testing.T) {
+		for _, enforcement := range []string{"ingress-collision", "unknown-rule=warn", "ingress-collision=maybe"} {
+			config := &config{RuleEnforcement: []string{enforcement}}
+			assert.Error(t, config.init(), enforcement)
+		}
+	})
+}
+
+func TestAuditMode(t *testing.T) {
+	initLogger()
+
+	t.Run("should allow and record what would have been denied", func(t *testing.T) {
+		audited := testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "audited"))
+
+		config := &config{Mode: modeAudit, RuleResourceLimitCPURequired: true}
+		response := validate(admissionReview("Pod", incompletePod), config, nil)
+		assert.True(t, response.Allowed)
+		assert.Nil(t, response.Result)
+		assert.Contains(t, response.AuditAnnotations["would-deny"], "'cpu' resource limit must be specified")
+		assert.Equal(t, audited+1, testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "audited")))
+	})
+
+	t.Run("should allow and record objects failing to be validated", func(t *testing.T) {
+		audited := testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "audited"))
+		denied := testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "denied"))
+
+		response := validate(admissionReview("Pod", `{"spec": "invalid"}`), &config{Mode: modeAudit}, nil)
+		assert.True(t, response.Allowed)
+		assert.Nil(t, response.Result)
+		assert.NotEmpty(t, response.AuditAnnotations["would-deny"])
+		assert.Equal(t, audited+1, testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "audited")))
+
+		response = validate(admissionReview("Pod", `{"spec": "invalid"}`), &config{}, nil)
+		assert.False(t, response.Allowed)
+		assert.Equal(t, denied+1, testutil.ToFloat64(admissionRequests.WithLabelValues("Pod", "", "", "denied")))
+	})
+}
+
+func TestViolationCauses(t *testing.T) {
+	initLogger()
+
+	t.Run("should describe every violation by a cause", func(t *testing.T) {
+		config := &config{
+			RuleResourceLimitCPURequired:               true,
+			RuleSecurityReadonlyRootFilesystemRequired: true,
+			RuleEnforcement:                            []string{"security-readonly-rootfs-required=warn"},
+		}
+		assert.NoError(t, config.init())
+		deployment := `{
+			"metadata": {"name": "deployment", "namespace": "test"},
+			"spec": {"template": {"spec": {"containers": [
+				{"name": "app", "resources": {"limits": {"cpu": "1"}}},
+				{"name": "sidecar"}
+			]}}}
+		}`
+		response := validate(admissionReview("Deployment", deployment), config, nil)
+		assert.False(t, response.Allowed)
+		assert.Equal(t, metav1.StatusReasonInvalid, response.Result.Reason)
+		assert.Equal(t, "deployment", response.Result.Details.Name)
+		assert.Equal(t, "Deployment", response.Result.Details.Kind)
+		assert.Equal(t, []metav1.StatusCause{
+			{
+				Type:    "resource-limit-cpu-required",
+				Message: "deny: Container sidecar: 'cpu' resource limit must be specified.",
+				Field:   "spec.template.spec.containers[1].resources.limits.cpu",
+			},
+			{
+				Type:    "security-readonly-rootfs-required",
+				Message: "warn: Container app: 'securityContext' with 'readOnlyRootFilesystem: true' must be specified.",
+				Field:   "spec.template.spec.containers[0].securityContext.readOnlyRootFilesystem",
+			},
+			{
+				Type:    "security-readonly-rootfs-required",
+				Message: "warn: Container sidecar: 'securityContext' with 'readOnlyRootFilesystem: true' must be specified.",
+				Field:   "spec.template.spec.containers[1].securityContext.readOnlyRootFilesystem",
+			},
+		}, response.Result.Details.Causes)
+	})
+}
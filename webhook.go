@@ -1,21 +1,20 @@
 package main
 
 import (
+	"crypto/tls"
 	"strings"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"k8s.io/api/admission/v1beta1"
-	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
-	batchv1beta1 "k8s.io/api/batch/v1beta1"
-	corev1 "k8s.io/api/core/v1"
-	extv1beta1 "k8s.io/api/extensions/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -38,8 +37,64 @@ func init() {
 		"Do not use TLS.")
 	webhookCmd.Flags().String("tls-private-key-file", "",
 		"Path to the certificate key file. Required, unless --no-tls is set.")
+	webhookCmd.Flags().Duration("tls-reload-interval", 10*time.Second,
+		"How often the certificate files are checked for changes, to be reloaded without a restart.")
+	webhookCmd.Flags().Bool("self-signed-tls", false,
+		"Generate a CA and a serving certificate instead of using --tls-cert-file and --tls-private-key-file. They are kept in a Secret shared by all replicas, renewed before they expire, and the CA is injected into the webhook configurations.")
+	webhookCmd.Flags().String("self-signed-tls-service-dns-name", "",
+		"DNS name of the webhook Service the self-signed certificate is issued for, e.g. 'k8s-admission-webhook.default.svc'. Required with --self-signed-tls.")
+	webhookCmd.Flags().String("self-signed-tls-secret-namespace", "default",
+		"Namespace of the Secret keeping the self-signed certificates.")
+	webhookCmd.Flags().String("self-signed-tls-secret-name", "k8s-admission-webhook-tls",
+		"Name of the Secret keeping the self-signed certificates.")
+	webhookCmd.Flags().Duration("self-signed-tls-validity", 365*24*time.Hour,
+		"Validity of the self-signed serving certificate. The CA is valid ten times longer.")
+	webhookCmd.Flags().Duration("self-signed-tls-renew-before", 30*24*time.Hour,
+		"How long before expiry the self-signed certificates are renewed.")
+	webhookCmd.Flags().String("webhook-configuration-name", "k8s-admission-webhook-cfg",
+		"Name of the ValidatingWebhookConfiguration of this webhook, the self-signed CA is injected into it.")
+	webhookCmd.Flags().String("mutating-webhook-configuration-name", "",
+		"Name of the MutatingWebhookConfiguration of this webhook, the self-signed CA is injected into it if set.")
+	webhookCmd.Flags().Bool("register-webhook", false,
+		"Create or update the ValidatingWebhookConfiguration named by --webhook-configuration-name on start, intercepting only the kinds the enabled rules apply to.")
+	webhookCmd.Flags().String("webhook-service-name", "k8s-admission-webhook",
+		"Name of the Service of this webhook, used by --register-webhook.")
+	webhookCmd.Flags().String("webhook-service-namespace", "default",
+		"Namespace of the Service of this webhook, used by --register-webhook.")
+	webhookCmd.Flags().String("webhook-ca-bundle-file", "",
+		"Path to the CA bundle the webhook certificate is signed by, used by --register-webhook. If not set, the current caBundle is kept (or injected by --self-signed-tls).")
+	webhookCmd.Flags().String("webhook-failure-policy", "Fail",
+		"Failure policy of the registered webhook, either 'Fail' or 'Ignore'.")
+	webhookCmd.Flags().Int32("webhook-timeout-seconds", 10,
+		"Timeout of the registered webhook, between 1 and 30 seconds.")
+	webhookCmd.Flags().String("webhook-namespace-selector", "",
+		"Label selector of namespaces the registered webhook applies to, e.g. 'webhook=enabled'. Empty selects all namespaces.")
+	webhookCmd.Flags().Duration("policy-reload-interval", 10*time.Second,
+		"How often the --policy-file is checked for changes, to be reloaded without a restart.")
+	webhookCmd.Flags().Duration("namespace-cache-ttl", time.Minute,
+		"How long namespaces looked up for their overrides and resource defaults are cached.")
+	webhookCmd.Flags().StringSlice("exclude-users", []string{},
+		"Users whose requests are never denied, e.g. a break-glass account.")
+	webhookCmd.Flags().StringSlice("exclude-groups", []string{},
+		"Groups whose members' requests are never denied, e.g. 'system:nodes' creating mirror pods.")
+	webhookCmd.Flags().StringSlice("exclude-service-accounts", []string{},
+		"Service accounts, as '<namespace>/<name>', whose requests are never denied.")
 	webhookCmd.Flags().Int32("listen-port", 443,
 		"Port to listen on.")
+	webhookCmd.Flags().Int32("health-listen-port", 0,
+		"Port to serve /healthz and /readyz on over plain HTTP. If 0, they are served on --listen-port.")
+	webhookCmd.Flags().String("mode", modeEnforce,
+		"Either 'enforce' to deny invalid objects, or 'audit' to allow every object and only log what would have been denied.")
+
+	//mutation
+	webhookCmd.Flags().String("default-resource-limit-cpu", "",
+		"Default 'cpu' limit set by /mutate on containers without one. Can be overridden by namespace annotation.")
+	webhookCmd.Flags().String("default-resource-limit-memory", "",
+		"Default 'memory' limit set by /mutate on containers without one. Can be overridden by namespace annotation.")
+	webhookCmd.Flags().String("default-resource-request-cpu", "",
+		"Default 'cpu' request set by /mutate on containers without one. Can be overridden by namespace annotation.")
+	webhookCmd.Flags().String("default-resource-request-memory", "",
+		"Default 'memory' request set by /mutate on containers without one. Can be overridden by namespace annotation.")
 
 	initCommonFlags(webhookCmd)
 
@@ -52,13 +107,49 @@ func init() {
 }
 
 func startWebhook(cmd *cobra.Command, args []string) {
-	config := &config{}
-	if err := webhookViper.Unmarshal(config); err != nil {
+	flagConfig, err := loadConfig(webhookViper)
+	if err != nil {
 		errorWithUsage(err)
 	}
+	config, err := flagConfig.withPolicyFile()
+	if err != nil {
+		errorWithUsage(err)
+	}
+
+	if config.SelfSignedTLS {
+		if config.NoTLS {
+			errorWithUsage(errors.New("--self-signed-tls cannot be combined with --no-tls"))
+		}
+		if config.SelfSignedTLSServiceDNSName == "" {
+			errorWithUsage(errors.New("--self-signed-tls-service-dns-name is required with --self-signed-tls"))
+		}
+		if config.SelfSignedTLSValidity <= config.SelfSignedTLSRenewBefore {
+			errorWithUsage(errors.New("--self-signed-tls-validity must be longer than --self-signed-tls-renew-before"))
+		}
+	} else if !config.NoTLS && (config.TLSPrivateKeyFile == "" || config.TLSCertFile == "") {
+		errorWithUsage(errors.New("Both --tls-cert-file and --tls-private-key-file are required (unless TLS is disabled by setting --no-tls or generated by --self-signed-tls)"))
+	}
+
+	if !config.NoTLS && config.TLSReloadInterval <= 0 {
+		errorWithUsage(errors.New("--tls-reload-interval must be positive"))
+	}
 
-	if !config.NoTLS && (config.TLSPrivateKeyFile == "" || config.TLSCertFile == "") {
-		errorWithUsage(errors.New("Both --tls-cert-file and --tls-private-key-file are required (unless TLS is disabled by setting --no-tls)"))
+	if config.PolicyFile != "" && config.PolicyReloadInterval <= 0 {
+		errorWithUsage(errors.New("--policy-reload-interval must be positive"))
+	}
+
+	if config.Mode != modeEnforce && config.Mode != modeAudit {
+		errorWithUsage(fmt.Errorf("Invalid --mode '%s', expected '%s' or '%s'", config.Mode, modeEnforce, modeAudit))
+	}
+
+	if _, err := config.resourceDefaults(nil, ""); err != nil {
+		errorWithUsage(err)
+	}
+
+	if config.RegisterWebhook {
+		if _, err := config.validatingWebhook(nil); err != nil {
+			errorWithUsage(err)
+		}
 	}
 
 	log.Debugf("Configuration is: %+v", config)
@@ -68,17 +159,76 @@ func startWebhook(cmd *cobra.Command, args []string) {
 	if kubeClientSetErr != nil {
 		log.Fatal(kubeClientSetErr)
 	}
+	namespaces := newNamespaceCache(kubeClientSet, config.NamespaceCacheTTL)
+	flagConfig.namespaces, config.namespaces = namespaces, namespaces
+
+	// registered first, so that the self-signed CA can be injected into it
+	if config.RegisterWebhook {
+		caBundle, err := readCABundle(config.WebhookCABundleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := registerWebhook(kubeClientSet, config, caBundle); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	http.HandleFunc("/validate", admitFunc(validate).serve(config, kubeClientSet))
+	configs := &configStore{config: config}
+	if config.PolicyFile != "" {
+		watcher := &policyFileWatcher{flags: flagConfig, configs: configs}
+		if err := watcher.load(); err != nil {
+			log.Fatal(err)
+		}
+		go watcher.watch(config.PolicyReloadInterval)
+	}
+
+	http.HandleFunc("/validate", admitFunc(validate).serve(configs, kubeClientSet))
+	http.HandleFunc("/mutate", admitFunc(mutate).serve(configs, kubeClientSet))
+	http.Handle("/metrics", promhttp.Handler())
 
 	addr := fmt.Sprintf(":%v", config.ListenPort)
+	server := &http.Server{Addr: addr}
+	checks := readinessChecks{}
+	if !config.NoTLS {
+		certificates := &certificateHolder{}
+		if config.SelfSignedTLS {
+			selfSigned := newSelfSignedTLS(kubeClientSet, certificates, config)
+			if err := selfSigned.ensure(); err != nil {
+				log.Fatal(err)
+			}
+			go selfSigned.run(selfSignedTLSCheckInterval)
+		} else {
+			watcher := &certificateFileWatcher{certFile: config.TLSCertFile, keyFile: config.TLSPrivateKeyFile, holder: certificates}
+			if err := watcher.load(); err != nil {
+				log.Fatal(err)
+			}
+			go watcher.watch(config.TLSReloadInterval)
+		}
+
+		server.TLSConfig = &tls.Config{GetCertificate: certificates.GetCertificate}
+		checks = append(checks, certificateCheck(certificates.get))
+	}
+	checks = append(checks, ingressListCheck(kubeClientSet, configs))
+
+	if config.HealthListenPort != 0 {
+		healthMux := http.NewServeMux()
+		checks.register(healthMux)
+		healthAddr := fmt.Sprintf(":%v", config.HealthListenPort)
+		go func() {
+			log.Infof("Starting health endpoints at %v (no TLS)", healthAddr)
+			log.Fatal(http.ListenAndServe(healthAddr, healthMux))
+		}()
+	} else {
+		checks.register(http.DefaultServeMux)
+	}
+
 	var httpErr error
 	if config.NoTLS {
 		log.Infof("Starting webserver at %v (no TLS)", addr)
-		httpErr = http.ListenAndServe(addr, nil)
+		httpErr = server.ListenAndServe()
 	} else {
 		log.Infof("Starting webserver at %v (TLS)", addr)
-		httpErr = http.ListenAndServeTLS(addr, config.TLSCertFile, config.TLSPrivateKeyFile, nil)
+		httpErr = server.ListenAndServeTLS("", "")
 	}
 
 	if httpErr != nil {
@@ -88,125 +238,111 @@ func startWebhook(cmd *cobra.Command, args []string) {
 	}
 }
 
-func validate(ar v1beta1.AdmissionReview, config *config, clientSet *kubernetes.Clientset) *v1beta1.AdmissionResponse {
-	validation := &objectValidation{ar.Request.Kind.Kind, nil, &validationViolationSet{}}
-	deserializer := codecs.UniversalDeserializer()
-
-	raw := ar.Request.Object.Raw
-	var configMessage string
-	switch ar.Request.Kind.Kind {
-	case "Pod":
-		configMessage = config.RuleResourceViolationMessage
-		pod := corev1.Pod{}
-		if _, _, err := deserializer.Decode(raw, nil, &pod); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting Pod: %+v", pod)
-		validation.ObjMeta = &pod.ObjectMeta
-		validatePodSpec(validation, &pod.ObjectMeta, &pod.Spec, config)
-
-	case "ReplicaSet":
-		configMessage = config.RuleResourceViolationMessage
-		replicaSet := appsv1.ReplicaSet{}
-		if _, _, err := deserializer.Decode(raw, nil, &replicaSet); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
+func validate(ar admissionv1.AdmissionReview, config *config, clientSet *kubernetes.Clientset) *admissionv1.AdmissionResponse {
+	timer := prometheus.NewTimer(validationDuration)
+	defer timer.ObserveDuration()
 
-		log.Debugf("Admitting ReplicaSet: %+v", replicaSet)
-		validation.ObjMeta = &replicaSet.ObjectMeta
-		validatePodSpec(validation, &replicaSet.Spec.Template.ObjectMeta, &replicaSet.Spec.Template.Spec, config)
-
-	case "Deployment":
-		configMessage = config.RuleResourceViolationMessage
-		deployment := appsv1.Deployment{}
-		if _, _, err := deserializer.Decode(raw, nil, &deployment); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting deployment: %+v", deployment)
-		validation.ObjMeta = &deployment.ObjectMeta
-		validatePodSpec(validation, &deployment.Spec.Template.ObjectMeta, &deployment.Spec.Template.Spec, config)
-
-	case "DaemonSet":
-		configMessage = config.RuleResourceViolationMessage
-		daemonSet := appsv1.DaemonSet{}
-		if _, _, err := deserializer.Decode(raw, nil, &daemonSet); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting DaemonSet: %+v", daemonSet)
-		validation.ObjMeta = &daemonSet.ObjectMeta
-		validatePodSpec(validation, &daemonSet.Spec.Template.ObjectMeta, &daemonSet.Spec.Template.Spec, config)
-
-	case "Job":
-		configMessage = config.RuleResourceViolationMessage
-		job := batchv1.Job{}
-		if _, _, err := deserializer.Decode(raw, nil, &job); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting Job: %+v", job)
-		validation.ObjMeta = &job.ObjectMeta
-		validatePodSpec(validation, &job.Spec.Template.ObjectMeta, &job.Spec.Template.Spec, config)
-
-	case "CronJob":
-		configMessage = config.RuleResourceViolationMessage
-		cronJob := batchv1beta1.CronJob{}
-		if _, _, err := deserializer.Decode(raw, nil, &cronJob); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting CronJob: %+v", cronJob)
-		validation.ObjMeta = &cronJob.ObjectMeta
-		validatePodSpec(validation, &cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta, &cronJob.Spec.JobTemplate.Spec.Template.Spec, config)
-
-	case "Ingress":
-		configMessage = config.RuleIngressViolationMessage
-		ingress := extv1beta1.Ingress{}
-		if _, _, err := deserializer.Decode(raw, nil, &ingress); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
+	if reason := config.exclusionReason(ar.Request.Namespace, &ar.Request.UserInfo); reason != "" {
+		log.Infof("Allowing %s '%s/%s' without validation, %s", ar.Request.Kind.Kind, ar.Request.Namespace, ar.Request.Name, reason)
+		admissionRequests.WithLabelValues(ar.Request.Kind.Kind, ar.Request.Namespace, string(ar.Request.Operation), "excluded").Inc()
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
 
-		log.Debugf("Admitting Ingress: %+v", ingress)
-		validation.ObjMeta = &ingress.ObjectMeta
-		err := ValidateIngress(validation, &ingress, config, clientSet)
-		if err != nil {
-			return toAdmissionResponse(err)
-		}
+	config = config.forNamespace(ar.Request.Namespace)
 
-	case "StatefulSet":
-		configMessage = config.RuleResourceViolationMessage
-		statefulSet := appsv1.StatefulSet{}
-		if _, _, err := deserializer.Decode(raw, nil, &statefulSet); err != nil {
-			log.Error(err)
-			return toAdmissionResponse(err)
-		}
-
-		log.Debugf("Admitting stateful set: %+v", statefulSet)
-		validation.ObjMeta = &statefulSet.ObjectMeta
-		validatePodSpec(validation, &statefulSet.Spec.Template.ObjectMeta, &statefulSet.Spec.Template.Spec, config)
+	validation := &objectValidation{ar.Request.Kind.Kind, nil, &validationViolationSet{}}
+	configMessage := config.violationMessage(ar.Request.Kind.Kind)
 
-	default:
+	object, err := decodeAdmittedObject(ar.Request.Kind.Kind, ar.Request.Object.Raw)
+	if err != nil {
+		return recordDecision(ar.Request, decodeErrorResponse(ar.Request.Kind.Kind, err, config))
+	}
+	log.Debugf("Admitting %s: %+v", object.Kind, object.ObjMeta)
+	if object.ObjMeta.Namespace == "" {
+		object.ObjMeta.Namespace = ar.Request.Namespace
+	}
+	object.OldRaw = ar.Request.OldObject.Raw
+	object.UserInfo = &ar.Request.UserInfo
+	object.ClientSet = clientSet
+	validation.ObjMeta = object.ObjMeta
+	if !config.kindActive(object.Kind) {
 		log.Warnf("Admitted an unexpected resource: %v", ar.Request.Kind)
 	}
+	if err := checkRules(validation, object, config); err != nil {
+		log.Error(err)
+		return recordDecision(ar.Request, errorResponse(err, config))
+	}
 
-	reviewResponse := v1beta1.AdmissionResponse{}
+	reviewResponse := admissionv1.AdmissionResponse{}
+
+	now := time.Now()
+	exemption, exempted, err := exemptViolations(validation, object, config, now)
+	if err != nil {
+		log.Warnf("Ignoring exemption of %s '%s/%s': %v", object.Kind, object.ObjMeta.Namespace, object.ObjMeta.Name, err)
+		reviewResponse.Warnings = append(reviewResponse.Warnings, fmt.Sprintf("Exemption ignored: %v", err))
+	} else if exemption != nil && exemption.expired(now) {
+		reviewResponse.Warnings = append(reviewResponse.Warnings, fmt.Sprintf("Exemption expired on %s", exemption.Until.Format(time.RFC3339)))
+	} else if len(exempted.Violations) > 0 {
+		log.Infof("%s '%s/%s' is %s, ignoring: %s", object.Kind, object.ObjMeta.Namespace, object.ObjMeta.Name, exemption, exempted.message())
+	}
+
+	denied, warned := validation.Violations.enforced(config)
+	reviewResponse.Warnings = append(reviewResponse.Warnings, warned.warnings()...)
+	recordViolations(denied, enforcementDeny)
+	recordViolations(warned, enforcementWarn)
 
+	validation.Violations = denied
 	message := validation.message(configMessage)
-	if len(message) > 0 {
+	if len(message) > 0 && config.Mode == modeAudit {
+		// the denial is only recorded, both in the log and in the cluster audit log
+		log.Warnf("Audit mode, would deny %s: %s", ar.Request.Kind.Kind, message)
+		reviewResponse.Allowed = true
+		reviewResponse.AuditAnnotations = map[string]string{"would-deny": message}
+	} else if len(message) > 0 {
 		reviewResponse.Allowed = false
-		reviewResponse.Result = &metav1.Status{Message: message}
+		reviewResponse.Result = &metav1.Status{
+			Message: message,
+			Reason:  metav1.StatusReasonInvalid,
+			Details: &metav1.StatusDetails{
+				Name:   object.ObjMeta.Name,
+				Kind:   object.Kind,
+				Causes: append(denied.causes(), warned.causes()...),
+			},
+		}
 	} else {
 		reviewResponse.Allowed = true
 	}
+	return recordDecision(ar.Request, &reviewResponse)
+}
+
+// Counts the validated request by the decision of the response, including
+// responses to objects which could not be decoded or checked.
+func recordDecision(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	decision := "allowed"
+	if !response.Allowed {
+		decision = "denied"
+	} else if _, audited := response.AuditAnnotations["would-deny"]; audited {
+		decision = "audited"
+	}
+	admissionRequests.WithLabelValues(request.Kind.Kind, request.Namespace, string(request.Operation), decision).Inc()
+	return response
+}
 
-	return &reviewResponse
+func decodeErrorResponse(kind string, err error, config *config) *admissionv1.AdmissionResponse {
+	log.Error(err)
+	decodeErrors.WithLabelValues(kind).Inc()
+	return errorResponse(err, config)
+}
+
+// Denies the object for the error, unless in audit mode, which never denies
+// and only records the denial like for violations.
+func errorResponse(err error, config *config) *admissionv1.AdmissionResponse {
+	if config.Mode == modeAudit {
+		log.Warnf("Audit mode, would deny on error: %v", err)
+		return &admissionv1.AdmissionResponse{
+			Allowed:          true,
+			AuditAnnotations: map[string]string{"would-deny": err.Error()},
+		}
+	}
+	return toAdmissionResponse(err)
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const registeredWebhookName = "k8s-admission-webhook.avast.com"
+
+// API groups and resources of the kinds rules apply to.
+var kindResources = map[string]admissionregistrationv1.Rule{
+	"Pod":         {APIGroups: []string{""}, Resources: []string{"pods"}},
+	"ReplicaSet":  {APIGroups: []string{"apps"}, Resources: []string{"replicasets"}},
+	"Deployment":  {APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+	"DaemonSet":   {APIGroups: []string{"apps"}, Resources: []string{"daemonsets"}},
+	"StatefulSet": {APIGroups: []string{"apps"}, Resources: []string{"statefulsets"}},
+	"Job":         {APIGroups: []string{"batch"}, Resources: []string{"jobs"}},
+	"CronJob":     {APIGroups: []string{"batch"}, Resources: []string{"cronjobs"}},
+	"Ingress":     {APIGroups: []string{"extensions", "networking.k8s.io"}, Resources: []string{"ingresses"}},
+}
+
+// Creates or updates the ValidatingWebhookConfiguration, so that it intercepts
+// only the kinds checked by the enabled rules. The caBundle is kept as it is
+// unless given, e.g. because it is injected by the self-signed TLS.
+func registerWebhook(clientSet kubernetes.Interface, config *config, caBundle []byte) error {
+	webhook, err := config.validatingWebhook(caBundle)
+	if err != nil {
+		return err
+	}
+	if len(webhook.Rules) == 0 {
+		log.Warn("No rule is enabled, the registered webhook will not intercept anything")
+	}
+
+	configs := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+	existing, err := configs.Get(context.TODO(), config.WebhookConfigurationName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configs.Create(context.TODO(), &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: config.WebhookConfigurationName},
+			Webhooks:   []admissionregistrationv1.ValidatingWebhook{*webhook},
+		}, metav1.CreateOptions{})
+		if err == nil {
+			log.Infof("Created ValidatingWebhookConfiguration '%s' for rules %+v", config.WebhookConfigurationName, webhook.Rules)
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	updated := existing.DeepCopy()
+	if webhook.ClientConfig.CABundle == nil {
+		for _, existingWebhook := range existing.Webhooks {
+			if existingWebhook.Name == webhook.Name {
+				webhook.ClientConfig.CABundle = existingWebhook.ClientConfig.CABundle
+			}
+		}
+	}
+	updated.Webhooks = []admissionregistrationv1.ValidatingWebhook{*webhook}
+	if _, err := configs.Update(context.TODO(), updated, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	log.Infof("Updated ValidatingWebhookConfiguration '%s' for rules %+v", config.WebhookConfigurationName, webhook.Rules)
+	return nil
+}
+
+func (config *config) validatingWebhook(caBundle []byte) (*admissionregistrationv1.ValidatingWebhook, error) {
+	failurePolicy := admissionregistrationv1.FailurePolicyType(config.WebhookFailurePolicy)
+	if failurePolicy != admissionregistrationv1.Fail && failurePolicy != admissionregistrationv1.Ignore {
+		return nil, fmt.Errorf("Invalid webhook failure policy '%s', expected '%s' or '%s'", failurePolicy, admissionregistrationv1.Fail, admissionregistrationv1.Ignore)
+	}
+	if config.WebhookTimeoutSeconds < 1 || config.WebhookTimeoutSeconds > 30 {
+		return nil, fmt.Errorf("Invalid webhook timeout %d, must be between 1 and 30 seconds", config.WebhookTimeoutSeconds)
+	}
+	namespaceSelector, err := metav1.ParseToLabelSelector(config.WebhookNamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid webhook namespace selector '%s': %v", config.WebhookNamespaceSelector, err)
+	}
+
+	rules, err := config.webhookRules()
+	if err != nil {
+		return nil, err
+	}
+
+	path := "/validate"
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	timeoutSeconds := config.WebhookTimeoutSeconds
+	return &admissionregistrationv1.ValidatingWebhook{
+		Name: registeredWebhookName,
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Namespace: config.WebhookServiceNamespace,
+				Name:      config.WebhookServiceName,
+				Path:      &path,
+			},
+			CABundle: caBundle,
+		},
+		Rules:                   rules,
+		FailurePolicy:           &failurePolicy,
+		NamespaceSelector:       namespaceSelector,
+		SideEffects:             &sideEffects,
+		TimeoutSeconds:          &timeoutSeconds,
+		AdmissionReviewVersions: []string{"v1", "v1beta1"},
+	}, nil
+}
+
+// Returns the intercepted kinds, only those some active rule applies to.
+func (config *config) webhookRules() ([]admissionregistrationv1.RuleWithOperations, error) {
+	kinds, err := config.registeredKinds()
+	if err != nil {
+		return nil, err
+	}
+	var rules []admissionregistrationv1.RuleWithOperations
+	for _, kind := range kinds {
+		resource, ok := kindResources[kind]
+		if !ok {
+			log.Warnf("Kind %s is not known, it cannot be registered", kind)
+			continue
+		}
+		rules = append(rules, admissionregistrationv1.RuleWithOperations{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   resource.APIGroups,
+				APIVersions: []string{"*"},
+				Resources:   resource.Resources,
+			},
+		})
+	}
+	return rules, nil
+}
+
+// Returns the kinds of rules active in the config or in any of the policy
+// profiles. Namespace annotations can enable any rule, so with
+// --namespace-overrides the kinds of all rules are registered.
+func (config *config) registeredKinds() ([]string, error) {
+	var kinds []string
+	seen := map[string]bool{}
+	add := func(ruleKinds []string) {
+		for _, kind := range ruleKinds {
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	add(config.activeKinds())
+	var profileNames []string
+	for name := range config.profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		profileConfig, err := config.withPolicy(config.profiles[name])
+		if err != nil {
+			return nil, fmt.Errorf("Policy profile '%s': %v", name, err)
+		}
+		add(profileConfig.activeKinds())
+	}
+	if config.NamespaceOverrides {
+		for _, r := range config.rules() {
+			add(r.Kinds())
+		}
+	}
+	return kinds, nil
+}
+
+func readCABundle(caBundleFile string) ([]byte, error) {
+	if caBundleFile == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(caBundleFile)
+}
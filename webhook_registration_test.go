@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWebhookRegistration(t *testing.T) {
+	initLogger()
+
+	newConfig := func() *config {
+		return &config{
+			WebhookConfigurationName: "k8s-admission-webhook-cfg",
+			WebhookServiceName:       "k8s-admission-webhook",
+			WebhookServiceNamespace:  "default",
+			WebhookFailurePolicy:     "Ignore",
+			WebhookTimeoutSeconds:    5,
+			WebhookNamespaceSelector: "webhook=enabled",
+		}
+	}
+	registeredResources := func(clientSet *fake.Clientset) []string {
+		webhookConfig, err := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), "k8s-admission-webhook-cfg", metav1.GetOptions{})
+		assert.NoError(t, err)
+		var resources []string
+		for _, rule := range webhookConfig.Webhooks[0].Rules {
+			resources = append(resources, rule.Resources...)
+		}
+		return resources
+	}
+
+	t.Run("should register only kinds of enabled rules", func(t *testing.T) {
+		config := newConfig()
+		config.RuleResourceLimitCPURequired = true
+		clientSet := fake.NewSimpleClientset()
+		assert.NoError(t, registerWebhook(clientSet, config, []byte("ca")))
+
+		webhookConfig, _ := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), "k8s-admission-webhook-cfg", metav1.GetOptions{})
+		webhook := webhookConfig.Webhooks[0]
+		assert.Equal(t, admissionregistrationv1.Ignore, *webhook.FailurePolicy)
+		assert.Equal(t, int32(5), *webhook.TimeoutSeconds)
+		assert.Equal(t, map[string]string{"webhook": "enabled"}, webhook.NamespaceSelector.MatchLabels)
+		assert.Equal(t, "k8s-admission-webhook", webhook.ClientConfig.Service.Name)
+		assert.Equal(t, []byte("ca"), webhook.ClientConfig.CABundle)
+		assert.ElementsMatch(t, []string{"pods", "deployments", "replicasets", "daemonsets", "statefulsets", "jobs", "cronjobs"}, registeredResources(clientSet))
+	})
+
+	t.Run("should register ingresses only with the collision rule", func(t *testing.T) {
+		config := newConfig()
+		config.RuleIngressCollision = true
+		clientSet := fake.NewSimpleClientset()
+		assert.NoError(t, registerWebhook(clientSet, config, nil))
+		assert.Equal(t, []string{"ingresses"}, registeredResources(clientSet))
+	})
+
+	t.Run("should skip rules switched off", func(t *testing.T) {
+		config := newConfig()
+		config.RuleIngressCollision = true
+		config.RuleEnforcement = []string{"ingress-collision=off"}
+		assert.NoError(t, config.init())
+		webhook, err := config.validatingWebhook(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, webhook.Rules)
+	})
+
+	t.Run("should register kinds of rules enabled by profiles", func(t *testing.T) {
+		config, err := newConfig().withPolicyData([]byte(`
+profiles:
+  edge:
+    rules:
+      ingress-collision: {}
+`))
+		assert.NoError(t, err)
+		clientSet := fake.NewSimpleClientset()
+		assert.NoError(t, registerWebhook(clientSet, config, nil))
+		assert.Equal(t, []string{"ingresses"}, registeredResources(clientSet))
+	})
+
+	t.Run("should register all kinds with namespace overrides", func(t *testing.T) {
+		config := newConfig()
+		config.NamespaceOverrides = true
+		clientSet := fake.NewSimpleClientset()
+		assert.NoError(t, registerWebhook(clientSet, config, nil))
+		assert.ElementsMatch(t, []string{"pods", "deployments", "replicasets", "daemonsets", "statefulsets", "jobs", "cronjobs", "ingresses"}, registeredResources(clientSet))
+	})
+
+	t.Run("should update rules and keep the caBundle", func(t *testing.T) {
+		config := newConfig()
+		config.RuleIngressCollision = true
+		clientSet := fake.NewSimpleClientset()
+		assert.NoError(t, registerWebhook(clientSet, config, []byte("ca")))
+
+		config.RuleSecurityReadonlyRootFilesystemRequired = true
+		assert.NoError(t, registerWebhook(clientSet, config, nil))
+		assert.Contains(t, registeredResources(clientSet), "pods")
+		webhookConfig, _ := clientSet.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.TODO(), "k8s-admission-webhook-cfg", metav1.GetOptions{})
+		assert.Equal(t, []byte("ca"), webhookConfig.Webhooks[0].ClientConfig.CABundle)
+	})
+
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		config := newConfig()
+		config.WebhookFailurePolicy = "Retry"
+		_, err := config.validatingWebhook(nil)
+		assert.Error(t, err)
+
+		config = newConfig()
+		config.WebhookTimeoutSeconds = 31
+		_, err = config.validatingWebhook(nil)
+		assert.Error(t, err)
+
+		config = newConfig()
+		config.WebhookNamespaceSelector = "webhook in (enabled"
+		_, err = config.validatingWebhook(nil)
+		assert.Error(t, err)
+	})
+}